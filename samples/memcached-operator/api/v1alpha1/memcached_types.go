@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -35,6 +36,100 @@ type MemcachedSpec struct {
 	// +kubebuilder:validation:Maximum=3
 	// +kubebuilder:validation:ExclusiveMaximum=false
 	Size int32 `json:"size,omitempty"`
+
+	// ImagePullSecrets references secrets in the same namespace which are used to
+	// pull the memcached image from a private registry.
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// Probes overrides the default probes of the memcached container which
+	// talk the memcached text protocol.
+	// +optional
+	Probes *MemcachedProbes `json:"probes,omitempty"`
+
+	// TerminationGracePeriodSeconds is the time a memcached pod gets to leave the
+	// Service endpoints and shut down gracefully before it is killed. Defaults to 30.
+	// +kubebuilder:validation:Minimum=10
+	// +optional
+	TerminationGracePeriodSeconds *int64 `json:"terminationGracePeriodSeconds,omitempty"`
+
+	// WarmRestart keeps the cache of a pod across restarts of its memcached
+	// container, including the update to a new memcached image.
+	// +optional
+	WarmRestart *WarmRestartSpec `json:"warmRestart,omitempty"`
+
+	// RestartedAt requests a rolling restart of all memcached pods whenever the
+	// timestamp changes, e.g. to clear memory fragmentation.
+	// +optional
+	RestartedAt *metav1.Time `json:"restartedAt,omitempty"`
+
+	// DeletionPolicy decides what happens to the PersistentVolumeClaims of the
+	// Memcached when it is deleted. Claims of the Memcached are owned by it or
+	// annotated with cache.example.com/memcached set to its name. Defaults to Retain.
+	// +kubebuilder:default=Retain
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// FlushOnDelete invalidates all items of every pod with 'flush_all' before
+	// the Memcached is deleted.
+	// +optional
+	FlushOnDelete bool `json:"flushOnDelete,omitempty"`
+}
+
+// DeletionPolicy describes the cleanup of the PersistentVolumeClaims when the
+// Memcached is deleted.
+// +kubebuilder:validation:Enum=Retain;Delete
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyRetain keeps the PersistentVolumeClaims.
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicyDelete deletes the PersistentVolumeClaims.
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+)
+
+// WarmRestartSpec configures the restartable cache of memcached 1.6. The cache
+// is stored in a memory file on tmpfs which survives container restarts but not
+// the replacement of the pod. A new memcached image is rolled out by restarting
+// the containers in place, memcached restores the file across versions as long
+// as the memory settings are the same. Other changes of the pods roll them and
+// start cold.
+type WarmRestartSpec struct {
+	// Enabled stores the cache in a memory file and restores it after a graceful
+	// restart of the memcached container. It also starts memcached with -A, which
+	// enables the 'shutdown' command the Restart operation stops memcached with.
+	// The memcached protocol has no authentication, every client reaching port
+	// 11211 can stop memcached then. Restrict access to the pods, e.g. with a
+	// NetworkPolicy.
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+}
+
+// RestartType describes whether memcached restored its cache after a restart.
+// +kubebuilder:validation:Enum=Warm;Cold
+type RestartType string
+
+const (
+	// RestartTypeWarm means memcached restored its cache from the memory file.
+	RestartTypeWarm RestartType = "Warm"
+	// RestartTypeCold means memcached started with an empty cache.
+	RestartTypeCold RestartType = "Cold"
+)
+
+// MemcachedProbes defines the probes of the memcached container. A nil probe
+// falls back to the default probe of the operator.
+type MemcachedProbes struct {
+	// Readiness replaces the default readiness probe sending 'stats'.
+	// +optional
+	Readiness *corev1.Probe `json:"readiness,omitempty"`
+
+	// Liveness replaces the default liveness probe sending 'version'.
+	// +optional
+	Liveness *corev1.Probe `json:"liveness,omitempty"`
+
+	// Startup replaces the default startup probe sending 'version'.
+	// +optional
+	Startup *corev1.Probe `json:"startup,omitempty"`
 }
 
 // MemcachedStatus defines the observed state of Memcached.
@@ -42,6 +137,61 @@ type MemcachedStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// Pods is the observed state of each memcached pod.
+	// +optional
+	Pods []MemcachedPodStatus `json:"pods,omitempty"`
+
+	// LastRestart is the most recent restart of a memcached container or start
+	// of a pod created by a rollout.
+	// +optional
+	LastRestart *MemcachedRestartStatus `json:"lastRestart,omitempty"`
+
+	// LastCompletedRestartAt is the spec.restartedAt of the last rolling restart
+	// which completed.
+	// +optional
+	LastCompletedRestartAt *metav1.Time `json:"lastCompletedRestartAt,omitempty"`
+}
+
+// MemcachedRestartStatus describes a restart of a memcached container.
+type MemcachedRestartStatus struct {
+	// Pod is the name of the restarted pod.
+	Pod string `json:"pod"`
+
+	// Type is Warm if memcached restored its cache and Cold otherwise.
+	Type RestartType `json:"type"`
+
+	// Time of the restart.
+	Time metav1.Time `json:"time"`
+}
+
+// MemcachedPodStatus defines the observed state of a single memcached pod.
+type MemcachedPodStatus struct {
+	// Name of the pod.
+	Name string `json:"name"`
+
+	// Phase of the pod.
+	// +optional
+	Phase corev1.PodPhase `json:"phase,omitempty"`
+
+	// Ready is true when the readiness probe of the memcached container succeeds.
+	Ready bool `json:"ready"`
+
+	// Started is true when the startup probe of the memcached container succeeded.
+	Started bool `json:"started"`
+
+	// RestartCount is the number of restarts of the memcached container, e.g.
+	// after a failing liveness probe.
+	RestartCount int32 `json:"restartCount"`
+
+	// Message explains why the pod is not ready, e.g. a failing probe.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// LastRestart is the most recent restart of the memcached container. The
+	// first start of a pod created by a rollout is a cold restart.
+	// +optional
+	LastRestart *MemcachedRestartStatus `json:"lastRestart,omitempty"`
 }
 
 // +kubebuilder:object:root=true
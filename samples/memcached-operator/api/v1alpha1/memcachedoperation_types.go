@@ -0,0 +1,147 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MemcachedOperationAction is the one-off operation run against every pod of a Memcached.
+// +kubebuilder:validation:Enum=Flush;Restart;Stats
+type MemcachedOperationAction string
+
+const (
+	// MemcachedOperationFlush invalidates all items with 'flush_all'.
+	MemcachedOperationFlush MemcachedOperationAction = "Flush"
+	// MemcachedOperationRestart restarts the pods one after another. With warm restart
+	// memcached is stopped with 'shutdown graceful' and restores its cache, otherwise
+	// the pod is deleted and replaced.
+	MemcachedOperationRestart MemcachedOperationAction = "Restart"
+	// MemcachedOperationStats collects a snapshot of a fixed set of 'stats' keys.
+	MemcachedOperationStats MemcachedOperationAction = "Stats"
+)
+
+// MemcachedOperationPhase is the phase of an operation or of the operation on a single pod.
+// +kubebuilder:validation:Enum=Pending;Running;Succeeded;Failed
+type MemcachedOperationPhase string
+
+const (
+	// MemcachedOperationPending means the operation has not started yet.
+	MemcachedOperationPending MemcachedOperationPhase = "Pending"
+	// MemcachedOperationRunning means the operation has started but not finished.
+	MemcachedOperationRunning MemcachedOperationPhase = "Running"
+	// MemcachedOperationSucceeded means the operation succeeded. This phase is terminal.
+	MemcachedOperationSucceeded MemcachedOperationPhase = "Succeeded"
+	// MemcachedOperationFailed means the operation failed. This phase is terminal.
+	MemcachedOperationFailed MemcachedOperationPhase = "Failed"
+)
+
+// MemcachedOperationSpec defines the desired state of MemcachedOperation.
+// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="spec is immutable"
+type MemcachedOperationSpec struct {
+	// MemcachedRef references the Memcached in the same namespace the operation runs against.
+	MemcachedRef corev1.LocalObjectReference `json:"memcachedRef"`
+
+	// Action is the operation run against every pod of the Memcached.
+	Action MemcachedOperationAction `json:"action"`
+
+	// TTLSecondsAfterFinished limits the lifetime of a finished operation. The
+	// operation is deleted when the TTL expired. Defaults to one hour.
+	// +kubebuilder:default=3600
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+}
+
+// MemcachedOperationStatus defines the observed state of MemcachedOperation.
+type MemcachedOperationStatus struct {
+	// Phase of the operation. Succeeded and Failed are terminal.
+	// +optional
+	Phase MemcachedOperationPhase `json:"phase,omitempty"`
+
+	// StartTime is the time the operation started.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is the time the operation reached a terminal phase.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Message explains the phase, e.g. why the operation failed.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Pods contains the result of the operation for each pod.
+	// +optional
+	Pods []MemcachedOperationPodResult `json:"pods,omitempty"`
+}
+
+// MemcachedOperationPodResult is the result of an operation on a single pod.
+type MemcachedOperationPodResult struct {
+	// Name of the pod.
+	Name string `json:"name"`
+
+	// Phase of the operation on the pod.
+	Phase MemcachedOperationPhase `json:"phase"`
+
+	// StartTime is the time the command was sent to the pod.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is the time the operation on the pod finished.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Message contains the reply of memcached or the error.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Stats is the snapshot of 'stats' collected by the Stats action. Only a fixed
+	// set of keys is kept, e.g. curr_items, get_hits, get_misses and evictions.
+	// +optional
+	Stats map[string]string `json:"stats,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Memcached",type=string,JSONPath=`.spec.memcachedRef.name`
+// +kubebuilder:printcolumn:name="Action",type=string,JSONPath=`.spec.action`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// MemcachedOperation is the Schema for the memcachedoperations API.
+type MemcachedOperation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MemcachedOperationSpec   `json:"spec,omitempty"`
+	Status MemcachedOperationStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// MemcachedOperationList contains a list of MemcachedOperation.
+type MemcachedOperationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MemcachedOperation `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MemcachedOperation{}, &MemcachedOperationList{})
+}
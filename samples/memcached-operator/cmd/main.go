@@ -63,6 +63,7 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var registryMirrors string
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -81,6 +82,9 @@ func main() {
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&registryMirrors, "registry-mirrors", "",
+		"Comma separated list of registry rewrites used for the memcached image, "+
+			"e.g. docker.io=localhost:5000 to pull from a local registry.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -180,6 +184,7 @@ func main() {
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
+		Cache:                  controller.CacheOptions(),
 		Metrics:                metricsServerOptions,
 		WebhookServer:          webhookServer,
 		HealthProbeBindAddress: probeAddr,
@@ -202,14 +207,25 @@ func main() {
 		os.Exit(1)
 	}
 
+	mirrors, err := controller.ParseRegistryMirrors(registryMirrors)
+	if err != nil {
+		setupLog.Error(err, "unable to parse registry mirrors")
+		os.Exit(1)
+	}
+
 	if err = controller.NewReconciler(
 		mgr.GetScheme(),
 		mgr.GetClient(),
+		mgr.GetEventRecorderFor("memcached-controller"),
 		ctrl.SetControllerReference,
-	).SetupWithManager(mgr); err != nil {
+	).WithRegistryMirrors(mirrors).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Memcached")
 		os.Exit(1)
 	}
+	if err = controller.NewOperationReconciler(mgr.GetClient()).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "MemcachedOperation")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
 	if metricsCertWatcher != nil {
@@ -0,0 +1,79 @@
+package infra
+
+import (
+	"sync"
+	"time"
+)
+
+// ClockImpl is a Thin Wrapper (James Shore) encapsulating the Infrastructure
+// Wrapper and Embedded Stub for the wall clock. The reconcilers base their time
+// decisions on it, e.g. timeouts and TTLs, so that tests can advance the time
+// instead of waiting.
+type ClockImpl struct {
+	clock clock
+}
+
+// Package scoped interface which is used by the Thin Wrapper and implemented by
+// Infrastructure Wrapper and the Embedded Stub.
+type clock interface {
+	Now() time.Time
+}
+
+func NewClockImpl() *ClockImpl {
+	return &ClockImpl{clock: &clockActual{}}
+}
+
+// NewClockStub returns the Embedded Stub. Its time stands still at the time
+// it was created until it's advanced.
+func NewClockStub() *ClockImpl {
+	return &ClockImpl{clock: &clockStub{now: time.Now()}}
+}
+
+func (c *ClockImpl) Now() time.Time {
+	return c.clock.Now()
+}
+
+// Since returns the time elapsed since t.
+func (c *ClockImpl) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Until returns the duration until t.
+func (c *ClockImpl) Until(t time.Time) time.Duration {
+	return t.Sub(c.Now())
+}
+
+// Advance moves the time of the Embedded Stub forward by d. The wall clock
+// can't be advanced, Advance panics if it's not the stub.
+func (c *ClockImpl) Advance(d time.Duration) {
+	stub, ok := c.clock.(*clockStub)
+	if !ok {
+		panic("only the nulled clock can be advanced")
+	}
+	stub.advance(d)
+}
+
+// Infrastructure Wrapper which is the real implementation using the wall clock
+type clockActual struct{}
+
+func (c *clockActual) Now() time.Time {
+	return time.Now()
+}
+
+// Embedded Stub which returns the configured time. It's safe for concurrent use.
+type clockStub struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clockStub) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clockStub) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
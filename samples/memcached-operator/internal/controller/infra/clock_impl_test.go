@@ -0,0 +1,48 @@
+package infra_test
+
+import (
+	"testing"
+	"time"
+
+	"example.com/m/v2/internal/controller/infra"
+)
+
+func Test_Clock_stubStandsStillUntilAdvanced(t *testing.T) {
+	clock := infra.NewClockStub()
+	start := clock.Now()
+
+	if got := clock.Now(); !got.Equal(start) {
+		t.Errorf("expected %v, got %v", start, got)
+	}
+
+	clock.Advance(time.Minute)
+	if got := clock.Now(); !got.Equal(start.Add(time.Minute)) {
+		t.Errorf("expected %v, got %v", start.Add(time.Minute), got)
+	}
+	if got := clock.Since(start); got != time.Minute {
+		t.Errorf("expected %v since start, got %v", time.Minute, got)
+	}
+	if got := clock.Until(start.Add(time.Hour)); got != 59*time.Minute {
+		t.Errorf("expected %v until the hour, got %v", 59*time.Minute, got)
+	}
+}
+
+func Test_Clock_usesWallClock(t *testing.T) {
+	clock := infra.NewClockImpl()
+
+	before := time.Now()
+	got := clock.Now()
+	if got.Before(before) || got.After(time.Now()) {
+		t.Errorf("expected the wall clock time, got %v", got)
+	}
+}
+
+func Test_Clock_wallClockCannotBeAdvanced(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic, got nothing")
+		}
+	}()
+
+	infra.NewClockImpl().Advance(time.Minute)
+}
@@ -0,0 +1,98 @@
+package infra
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// EventRecorderImpl is a Thin Wrapper (James Shore) encapsulating the Infrastructure
+// Wrapper and Embedded Stub for the k8 event recorder. Besides forwarding events it
+// supports Output Tracking so that tests can assert on the emitted events.
+type EventRecorderImpl struct {
+	rec      eventRecorder
+	mu       sync.Mutex
+	trackers []*EventTracker
+}
+
+// Package scoped interface which is used by the Thin Wrapper and implemented by
+// Infrastructure Wrapper and the Embedded Stub.
+type eventRecorder interface {
+	Eventf(runtime.Object, string, string, string, ...interface{})
+}
+
+// Event is an emitted event as seen by the Output Tracking.
+type Event struct {
+	Type    string
+	Reason  string
+	Message string
+}
+
+func NewEventRecorderImpl(rec record.EventRecorder) *EventRecorderImpl {
+	return &EventRecorderImpl{rec: &eventRecorderActual{rec}}
+}
+
+func NewEventRecorderStub() *EventRecorderImpl {
+	return &EventRecorderImpl{rec: &eventRecorderStub{}}
+}
+
+func (r *EventRecorderImpl) Eventf(obj runtime.Object, eventType, reason, messageFmt string, args ...interface{}) {
+	event := Event{Type: eventType, Reason: reason, Message: fmt.Sprintf(messageFmt, args...)}
+	r.mu.Lock()
+	trackers := r.trackers
+	r.mu.Unlock()
+	for _, tracker := range trackers {
+		tracker.add(event)
+	}
+
+	r.rec.Eventf(obj, eventType, reason, messageFmt, args...)
+}
+
+// TrackEvents starts Output Tracking. The tracker records every event emitted
+// after it was created.
+func (r *EventRecorderImpl) TrackEvents() *EventTracker {
+	tracker := &EventTracker{}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trackers = append(r.trackers, tracker)
+	return tracker
+}
+
+// EventTracker records the events emitted by the EventRecorderImpl.
+type EventTracker struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (t *EventTracker) add(event Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event)
+}
+
+// Data returns the recorded events in the order they were emitted.
+func (t *EventTracker) Data() []Event {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Event{}, t.events...)
+}
+
+// Infrastructure Wrapper which is the real implementation using the k8 event recorder
+type eventRecorderActual struct {
+	rec record.EventRecorder
+}
+
+func (r *eventRecorderActual) Eventf(
+	obj runtime.Object,
+	eventType, reason, messageFmt string,
+	args ...interface{},
+) {
+	r.rec.Eventf(obj, eventType, reason, messageFmt, args...)
+}
+
+// Embedded Stub which discards all events.
+type eventRecorderStub struct{}
+
+func (r *eventRecorderStub) Eventf(runtime.Object, string, string, string, ...interface{}) {}
@@ -0,0 +1,72 @@
+package infra_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"example.com/m/v2/internal/controller/infra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func Test_EventRecorder_tracksEmittedEvents(t *testing.T) {
+	rec := infra.NewEventRecorderStub()
+	_, pod := tnnAndPod("event-pod", "default")
+
+	rec.Eventf(pod, corev1.EventTypeNormal, "Untracked", "before tracking")
+	tracker := rec.TrackEvents()
+	rec.Eventf(pod, corev1.EventTypeNormal, "Created", "created %s", "event-pod")
+	rec.Eventf(pod, corev1.EventTypeWarning, "Failed", "failed")
+
+	expected := []infra.Event{
+		{Type: corev1.EventTypeNormal, Reason: "Created", Message: "created event-pod"},
+		{Type: corev1.EventTypeWarning, Reason: "Failed", Message: "failed"},
+	}
+	if got := tracker.Data(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func Test_EventRecorder_propagatesEvents(t *testing.T) {
+	fake := record.NewFakeRecorder(1)
+	rec := infra.NewEventRecorderImpl(fake)
+	_, pod := tnnAndPod("event-pod", "default")
+
+	tracker := rec.TrackEvents()
+	rec.Eventf(pod, corev1.EventTypeNormal, "Created", "created %s", "event-pod")
+
+	if got, expected := <-fake.Events, "Normal Created created event-pod"; got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	if got := len(tracker.Data()); got != 1 {
+		t.Errorf("expected 1 tracked event, got %d", got)
+	}
+}
+
+// Run with -race to detect unsynchronized access to the trackers.
+func Test_EventRecorder_isSafeForConcurrentUse(t *testing.T) {
+	rec := infra.NewEventRecorderStub()
+	_, pod := tnnAndPod("concurrent-event-pod", "default")
+	const events = 50
+	tracker := rec.TrackEvents()
+
+	var wg sync.WaitGroup
+	for i := 0; i < events; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			rec.Eventf(pod, corev1.EventTypeNormal, "Created", "created %d", i)
+		}()
+		go func() {
+			defer wg.Done()
+			rec.TrackEvents()
+			_ = tracker.Data()
+		}()
+	}
+	wg.Wait()
+
+	if got := len(tracker.Data()); got != events {
+		t.Errorf("expected %d tracked events, got %d", events, got)
+	}
+}
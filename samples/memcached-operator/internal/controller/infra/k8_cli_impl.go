@@ -2,16 +2,35 @@ package infra
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"sync"
+	"time"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 // K8CliImpl is a Thin Wrapper (James Shore) encapsulating the Infrastructure Wrapper
 // and Embedded Stub for the k8 client. Its single job is to forward requests.
+// Besides forwarding it supports Output Tracking so that tests can assert on the
+// writes without reading them back from an API server. The latency and the
+// errors of every request are recorded in the controller-runtime metrics,
+// for the Embedded Stub as well. It's safe for concurrent use, e.g. by
+// reconciles with MaxConcurrentReconciles > 1.
 type K8CliImpl struct {
-	cli k8Cli
+	cli      k8Cli
+	scheme   *runtime.Scheme
+	mu       sync.Mutex
+	trackers []*WriteTracker
 }
 
 // Package scoped interface which is used by the Thin Wrapper and implemented by
@@ -21,34 +40,199 @@ type k8Cli interface {
 	StatusUpdate(context.Context, client.Object) error
 	Create(context.Context, client.Object) error
 	Update(context.Context, client.Object) error
+	List(context.Context, client.ObjectList, ...client.ListOption) error
+	Delete(context.Context, client.Object, ...client.DeleteOption) error
+	DeleteAllOf(context.Context, client.Object, ...client.DeleteAllOfOption) error
+	Patch(context.Context, client.Object, client.Patch, ...client.PatchOption) error
+	StatusPatch(context.Context, client.Object, client.Patch, ...client.SubResourcePatchOption) error
+	Apply(context.Context, client.Object, ...client.PatchOption) error
 }
 
 func NewK8CliImpl(k8 client.Client) *K8CliImpl {
-	return &K8CliImpl{&k8CliActual{k8}}
+	var s *runtime.Scheme
+	if k8 != nil {
+		s = k8.Scheme()
+	}
+	return &K8CliImpl{cli: &k8CliActual{k8}, scheme: s}
+}
+
+// StubConfig configures the Embedded Stub, the fields combine, e.g. a fault
+// delaying the calls which are then answered by the responses.
+type StubConfig struct {
+	// Scheme resolves the GVK of the objects and the types of the in-memory
+	// object store. It defaults to the scheme of K8 or the client-go scheme.
+	Scheme *runtime.Scheme
+	// Errors are returned for every object the method is called with, after
+	// the Responses. The returned errors are taken out of the map, stubs
+	// sharing the map share its errors.
+	Errors StubErrors
+	// Responses answer the calls for the objects of their keys.
+	Responses StubResponses
+	// Faults are injected into the calls they match before any response.
+	Faults StubFaults
+	// K8 answers the calls after the last response. Without it the calls are
+	// answered by the in-memory object store seeded with the Objects.
+	K8      client.Client
+	Objects []client.Object
 }
 
+// NewK8CliStub returns the Embedded Stub returning the errors of the map before
+// the k8 client answers the calls, or the empty in-memory object store without
+// a k8 client.
 func NewK8CliStub(e StubErrors, k8 client.Client) *K8CliImpl {
-	var cli k8Cli
-	if k8 != nil {
-		cli = &k8CliActual{k8}
+	return NewK8CliStubWithConfig(StubConfig{Errors: e, K8: k8})
+}
+
+// NewK8CliStubWithConfig returns the Embedded Stub configured by the config.
+func NewK8CliStubWithConfig(config StubConfig) *K8CliImpl {
+	s := config.Scheme
+	if s == nil && config.K8 != nil {
+		s = config.K8.Scheme()
+	}
+	if s == nil {
+		s = clientgoscheme.Scheme
 	}
-	return &K8CliImpl{&k8CliStub{e, cli}}
+
+	var cli k8Cli = &k8CliActual{config.K8}
+	if config.K8 == nil {
+		cli = newObjectStore(s, config.Objects...)
+	}
+	return &K8CliImpl{scheme: s, cli: &k8CliStub{
+		scheme:    s,
+		faults:    config.Faults,
+		calls:     make([]int, len(config.Faults)),
+		responses: config.Responses,
+		errs:      config.Errors,
+		cli:       cli,
+	}}
+}
+
+// Write is a successful write as seen by the Output Tracking. Action is the
+// name of the K8CliImpl method, Object a deep copy of the object as it was
+// sent. DeleteAllOf records the object passed to select the type.
+type Write struct {
+	Action string
+	Object client.Object
+}
+
+// TrackWrites starts Output Tracking. The tracker records every successful
+// write made after it was created, reads are not recorded.
+func (k8 *K8CliImpl) TrackWrites() *WriteTracker {
+	tracker := &WriteTracker{}
+	k8.mu.Lock()
+	defer k8.mu.Unlock()
+	k8.trackers = append(k8.trackers, tracker)
+	return tracker
+}
+
+// WriteTracker records the writes made with the K8CliImpl.
+type WriteTracker struct {
+	mu     sync.Mutex
+	writes []Write
+}
+
+func (t *WriteTracker) add(write Write) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.writes = append(t.writes, write)
+}
+
+// Data returns the recorded writes in the order they were made.
+func (t *WriteTracker) Data() []Write {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Write{}, t.writes...)
+}
+
+// track runs the observed write and records it with a copy of the object taken
+// before the write, the write may change the object, e.g. its resourceVersion.
+func (k8 *K8CliImpl) track(action string, co client.Object, write func() error) error {
+	k8.mu.Lock()
+	trackers := k8.trackers
+	k8.mu.Unlock()
+	if len(trackers) == 0 {
+		return k8.observe(action, co, write)
+	}
+
+	sent := co.DeepCopyObject().(client.Object)
+	if err := k8.observe(action, co, write); err != nil {
+		return err
+	}
+	for _, tracker := range trackers {
+		tracker.add(Write{Action: action, Object: sent})
+	}
+
+	return nil
 }
 
 func (k8 *K8CliImpl) Get(ctx context.Context, t types.NamespacedName, co client.Object) error {
-	return k8.cli.Get(ctx, t, co)
+	return k8.observe("Get", co, func() error {
+		return k8.cli.Get(ctx, t, co)
+	})
 }
 
 func (k8 *K8CliImpl) StatusUpdate(ctx context.Context, co client.Object) error {
-	return k8.cli.StatusUpdate(ctx, co)
+	return k8.track("StatusUpdate", co, func() error {
+		return k8.cli.StatusUpdate(ctx, co)
+	})
 }
 
 func (k8 *K8CliImpl) Create(ctx context.Context, co client.Object) error {
-	return k8.cli.Create(ctx, co)
+	return k8.track("Create", co, func() error {
+		return k8.cli.Create(ctx, co)
+	})
 }
 
 func (k8 *K8CliImpl) Update(ctx context.Context, co client.Object) error {
-	return k8.cli.Update(ctx, co)
+	return k8.track("Update", co, func() error {
+		return k8.cli.Update(ctx, co)
+	})
+}
+
+func (k8 *K8CliImpl) List(ctx context.Context, col client.ObjectList, opts ...client.ListOption) error {
+	return k8.observe("List", col, func() error {
+		return k8.cli.List(ctx, col, opts...)
+	})
+}
+
+func (k8 *K8CliImpl) Delete(ctx context.Context, co client.Object, opts ...client.DeleteOption) error {
+	return k8.track("Delete", co, func() error {
+		return k8.cli.Delete(ctx, co, opts...)
+	})
+}
+
+// DeleteAllOf deletes all objects of the type of the given object matching the
+// options, e.g. client.InNamespace and client.MatchingLabels.
+func (k8 *K8CliImpl) DeleteAllOf(ctx context.Context, co client.Object, opts ...client.DeleteAllOfOption) error {
+	return k8.track("DeleteAllOf", co, func() error {
+		return k8.cli.DeleteAllOf(ctx, co, opts...)
+	})
+}
+
+func (k8 *K8CliImpl) Patch(ctx context.Context, co client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return k8.track("Patch", co, func() error {
+		return k8.cli.Patch(ctx, co, patch, opts...)
+	})
+}
+
+// StatusPatch patches the status subresource of the object.
+func (k8 *K8CliImpl) StatusPatch(
+	ctx context.Context,
+	co client.Object,
+	patch client.Patch,
+	opts ...client.SubResourcePatchOption,
+) error {
+	return k8.track("StatusPatch", co, func() error {
+		return k8.cli.StatusPatch(ctx, co, patch, opts...)
+	})
+}
+
+// Apply sends the object as server-side apply patch. The object must have its
+// apiVersion and kind set and contain only the fields the field manager owns.
+func (k8 *K8CliImpl) Apply(ctx context.Context, co client.Object, opts ...client.PatchOption) error {
+	return k8.track("Apply", co, func() error {
+		return k8.cli.Apply(ctx, co, opts...)
+	})
 }
 
 // Infrastructure Wrapper which is the real implementation using the k8 client
@@ -72,73 +256,307 @@ func (k8 *k8CliActual) Update(ctx context.Context, co client.Object) error {
 	return k8.cli.Update(ctx, co)
 }
 
-// Configurable Responses. Key: method name, value: error slice.
+func (k8 *k8CliActual) List(ctx context.Context, col client.ObjectList, opts ...client.ListOption) error {
+	return k8.cli.List(ctx, col, opts...)
+}
+
+func (k8 *k8CliActual) Delete(ctx context.Context, co client.Object, opts ...client.DeleteOption) error {
+	return k8.cli.Delete(ctx, co, opts...)
+}
+
+func (k8 *k8CliActual) DeleteAllOf(ctx context.Context, co client.Object, opts ...client.DeleteAllOfOption) error {
+	return k8.cli.DeleteAllOf(ctx, co, opts...)
+}
+
+func (k8 *k8CliActual) Patch(ctx context.Context, co client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return k8.cli.Patch(ctx, co, patch, opts...)
+}
+
+func (k8 *k8CliActual) StatusPatch(
+	ctx context.Context,
+	co client.Object,
+	patch client.Patch,
+	opts ...client.SubResourcePatchOption,
+) error {
+	return k8.cli.Status().Patch(ctx, co, patch, opts...)
+}
+
+func (k8 *k8CliActual) Apply(ctx context.Context, co client.Object, opts ...client.PatchOption) error {
+	return k8.cli.Patch(ctx, co, client.Apply, opts...)
+}
+
+// Configurable Responses. Key: method name, value: error slice. The errors are
+// returned for every object the method is called with.
 type StubErrors = map[string][]error
 
-// Embedded Stub with Configurable Responses. It takes a map of error slices and
-// returns errors if the key is the name of an existing method. Each returned error
-// is removed from the slice. After the last error return it returns nil. If a k8
-// client is provided it forwards requests to it, if not it returns nil. Error
-// returns have higher precedence over the k8 client.
+// StubKey selects the calls of a method for one object. GVK and Key are empty
+// to select the calls for all objects. The Key of List calls is the namespace
+// of the list options.
+type StubKey struct {
+	Method string
+	GVK    schema.GroupVersionKind
+	Key    types.NamespacedName
+}
+
+// StubResponse is a Configurable Response. The stub returns Err if it's set,
+// otherwise it copies Object into the object of the caller, e.g. the object
+// read by Get or the list filled by List. An empty response forwards the call.
+type StubResponse struct {
+	Err    error
+	Object runtime.Object
+}
+
+// Configurable Responses. Key: method and object, value: ordered responses.
+type StubResponses = map[StubKey][]StubResponse
+
+// StubFault is a fault injected into the calls it matches. Method, GVK,
+// Namespace and Name select the calls, empty fields match every call. The GVK
+// of List calls is the kind of the list, e.g. PodList. Call selects the nth of
+// the selected calls counting from 1, 0 selects all of them. The matched call
+// is delayed by Latency, then it fails with Err or proceeds without it.
+type StubFault struct {
+	Method    string
+	GVK       schema.GroupVersionKind
+	Namespace string
+	Name      string
+	Call      int
+	Err       error
+	Latency   time.Duration
+}
+
+// StubFaults are the faults injected by the stub. Every fault counts its own
+// matching calls, if several faults match a call their latencies add up and
+// the error of the first one is returned.
+type StubFaults = []StubFault
+
+func (f StubFault) selects(method string, gvk schema.GroupVersionKind, key types.NamespacedName) bool {
+	return (f.Method == "" || f.Method == method) &&
+		(f.GVK.Empty() || f.GVK == gvk) &&
+		(f.Namespace == "" || f.Namespace == key.Namespace) &&
+		(f.Name == "" || f.Name == key.Name)
+}
+
+// ConflictFault returns a fault failing the nth call of the method for the
+// object with a 409 Conflict, the way the API server rejects the write of a
+// stale object. Call 0 fails every call.
+func ConflictFault(method string, gvk schema.GroupVersionKind, key types.NamespacedName, call int) StubFault {
+	resource, _ := meta.UnsafeGuessKindToResource(gvk)
+	return StubFault{
+		Method:    method,
+		GVK:       gvk,
+		Namespace: key.Namespace,
+		Name:      key.Name,
+		Call:      call,
+		Err: apierrors.NewConflict(resource.GroupResource(), key.Name,
+			errors.New("the object has been modified; please apply your changes to the latest version and try again")),
+	}
+}
+
+// Embedded Stub with Configurable Responses. It takes a map of response slices
+// and answers calls of the method for the object of the key. Each returned
+// response is removed from the slice, responses for the object are returned
+// before responses for all objects and before the errors of StubErrors. The
+// faults are injected before any response is returned. After
+// the last response it forwards the request to the k8 client if one is
+// provided, if not to the in-memory object store. Responses have higher
+// precedence over the k8 client and the store. The stub is safe for
+// concurrent use, concurrent calls take the responses in the order they lock
+// the stub. Diagnostics are logged with the logger of the context.
 type k8CliStub struct {
-	errArr StubErrors
-	cli    k8Cli
+	scheme    *runtime.Scheme
+	mu        sync.Mutex
+	faults    StubFaults
+	calls     []int
+	responses StubResponses
+	errs      StubErrors
+	cli       k8Cli
 }
 
 func (k8 *k8CliStub) Get(ctx context.Context, t types.NamespacedName, co client.Object) error {
-	return k8.do("Get", func() error {
+	return k8.do(ctx, "Get", co, t, func() error {
 		return k8.cli.Get(ctx, t, co)
 	})
 }
 
-func (k8 *k8CliStub) do(name string, action func() error) error {
-	if nextErr := k8.nextErr(name); nextErr != nil {
-		return nextErr
+func (k8 *k8CliStub) do(
+	ctx context.Context,
+	method string,
+	obj runtime.Object,
+	key types.NamespacedName,
+	action func() error,
+) error {
+	gvk, _ := apiutil.GVKForObject(obj, k8.scheme)
+	if err := k8.inject(ctx, method, gvk, key); err != nil {
+		return err
 	}
 
-	if k8.cli == nil {
-		return nil
+	response := k8.next(ctx, method, gvk, key)
+	if response.Err != nil {
+		return response.Err
+	}
+	if response.Object != nil {
+		return copyInto(obj, response.Object)
 	}
 
 	return action()
 }
 
-func (k8 *k8CliStub) nextErr(name string) error {
-	if k8.errArr == nil {
-		fmt.Println("no errors configured in nullable")
-		return nil
+// inject counts the call for every fault selecting it, waits for the latency
+// of the faults matching it and returns the first of their errors.
+func (k8 *k8CliStub) inject(ctx context.Context, method string, gvk schema.GroupVersionKind, key types.NamespacedName) error {
+	var latency time.Duration
+	var err error
+	k8.mu.Lock()
+	for i, fault := range k8.faults {
+		if !fault.selects(method, gvk, key) {
+			continue
+		}
+		k8.calls[i]++
+		if fault.Call != 0 && fault.Call != k8.calls[i] {
+			continue
+		}
+		latency += fault.Latency
+		if err == nil {
+			err = fault.Err
+		}
 	}
+	k8.mu.Unlock()
 
-	if _, ok := k8.errArr[name]; !ok {
-		fmt.Printf("no errors configured for '%s' method\n", name)
-		return nil
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
 	}
 
-	if len(k8.errArr[name]) == 0 {
-		fmt.Printf("no more errors configured in nulled '%s' method\n", name)
-		return nil
+	return err
+}
+
+func (k8 *k8CliStub) next(
+	ctx context.Context,
+	method string,
+	gvk schema.GroupVersionKind,
+	key types.NamespacedName,
+) StubResponse {
+	log := logf.FromContext(ctx).WithName("k8-cli-stub")
+	if k8.responses == nil && k8.errs == nil {
+		log.V(1).Info("no responses configured in nullable", "method", method, "key", key)
+		return StubResponse{}
 	}
 
-	err := k8.errArr[name][0]
-	k8.errArr[name] = k8.errArr[name][1:]
+	k8.mu.Lock()
+	defer k8.mu.Unlock()
 
-	return err
+	if !gvk.Empty() {
+		if response, ok := k8.pop(StubKey{Method: method, GVK: gvk, Key: key}); ok {
+			return response
+		}
+	}
+	if response, ok := k8.pop(StubKey{Method: method}); ok {
+		return response
+	}
+	if errs := k8.errs[method]; len(errs) > 0 {
+		k8.errs[method] = errs[1:]
+		return StubResponse{Err: errs[0]}
+	}
+
+	log.V(1).Info("no more responses configured in nullable", "method", method, "key", key)
+	return StubResponse{}
+}
+
+// pop takes the next response of the key, the caller holds the lock.
+func (k8 *k8CliStub) pop(key StubKey) (StubResponse, bool) {
+	if len(k8.responses[key]) == 0 {
+		return StubResponse{}, false
+	}
+
+	response := k8.responses[key][0]
+	k8.responses[key] = k8.responses[key][1:]
+
+	return response, true
+}
+
+// copyInto copies a deep copy of the configured object into the object of the
+// caller, both must be of the same type.
+func copyInto(dst, src runtime.Object) error {
+	dstValue, srcValue := reflect.ValueOf(dst), reflect.ValueOf(src.DeepCopyObject())
+	if dstValue.Type() != srcValue.Type() {
+		return fmt.Errorf("configured response of type %T can't be copied into %T", src, dst)
+	}
+	dstValue.Elem().Set(srcValue.Elem())
+
+	return nil
 }
 
 func (k8 *k8CliStub) StatusUpdate(ctx context.Context, co client.Object) error {
-	return k8.do("StatusUpdate", func() error {
+	return k8.do(ctx, "StatusUpdate", co, client.ObjectKeyFromObject(co), func() error {
 		return k8.cli.StatusUpdate(ctx, co)
 	})
 }
 
 func (k8 *k8CliStub) Create(ctx context.Context, co client.Object) error {
-	return k8.do("Create", func() error {
+	return k8.do(ctx, "Create", co, client.ObjectKeyFromObject(co), func() error {
 		return k8.cli.Create(ctx, co)
 	})
 }
 
 func (k8 *k8CliStub) Update(ctx context.Context, co client.Object) error {
-	return k8.do("Update", func() error {
+	return k8.do(ctx, "Update", co, client.ObjectKeyFromObject(co), func() error {
 		return k8.cli.Update(ctx, co)
 	})
 }
+
+func (k8 *k8CliStub) List(ctx context.Context, col client.ObjectList, opts ...client.ListOption) error {
+	return k8.do(ctx, "List", col, listKey(opts), func() error {
+		return k8.cli.List(ctx, col, opts...)
+	})
+}
+
+func (k8 *k8CliStub) Delete(ctx context.Context, co client.Object, opts ...client.DeleteOption) error {
+	return k8.do(ctx, "Delete", co, client.ObjectKeyFromObject(co), func() error {
+		return k8.cli.Delete(ctx, co, opts...)
+	})
+}
+
+func (k8 *k8CliStub) DeleteAllOf(ctx context.Context, co client.Object, opts ...client.DeleteAllOfOption) error {
+	return k8.do(ctx, "DeleteAllOf", co, deleteAllOfKey(opts), func() error {
+		return k8.cli.DeleteAllOf(ctx, co, opts...)
+	})
+}
+
+func (k8 *k8CliStub) Patch(ctx context.Context, co client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return k8.do(ctx, "Patch", co, client.ObjectKeyFromObject(co), func() error {
+		return k8.cli.Patch(ctx, co, patch, opts...)
+	})
+}
+
+func (k8 *k8CliStub) StatusPatch(
+	ctx context.Context,
+	co client.Object,
+	patch client.Patch,
+	opts ...client.SubResourcePatchOption,
+) error {
+	return k8.do(ctx, "StatusPatch", co, client.ObjectKeyFromObject(co), func() error {
+		return k8.cli.StatusPatch(ctx, co, patch, opts...)
+	})
+}
+
+func (k8 *k8CliStub) Apply(ctx context.Context, co client.Object, opts ...client.PatchOption) error {
+	return k8.do(ctx, "Apply", co, client.ObjectKeyFromObject(co), func() error {
+		return k8.cli.Apply(ctx, co, opts...)
+	})
+}
+
+// listKey returns the key of List responses, the namespace of the list.
+func listKey(opts []client.ListOption) types.NamespacedName {
+	return types.NamespacedName{Namespace: (&client.ListOptions{}).ApplyOptions(opts).Namespace}
+}
+
+// deleteAllOfKey returns the key of DeleteAllOf responses, the namespace of
+// the deleted objects.
+func deleteAllOfKey(opts []client.DeleteAllOfOption) types.NamespacedName {
+	return types.NamespacedName{Namespace: (&client.DeleteAllOfOptions{}).ApplyOptions(opts).Namespace}
+}
@@ -26,24 +26,27 @@ var (
 )
 
 // Test setup with test environment. Make sure to run 'make setup-envtest' to download
-// the binaries needed for tests using the k8 cli.
+// the binaries needed for tests using the k8 cli. Without the binaries only the
+// tests using the k8 cli are skipped, the tests of the stubs need no API server.
 func TestMain(m *testing.M) {
 	ctx = context.Background()
+	tnn, pod = tnnAndPod("non-existing-pod", "non-existing-ns")
 
-	testEnv := &envtest.Environment{
-		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "..", "config", "crd", "bases")},
-		ErrorIfCRDPathMissing: true,
+	if err := cachev1alpha1.AddToScheme(scheme.Scheme); err != nil {
+		fmt.Printf("failed to add scheme: %v\n", err)
+		os.Exit(1)
 	}
 
 	if binaryAssetsDir() == "" {
-		fmt.Printf("failed to load test env k8s binary, make sure binaries are downloaded with 'make setup-envtest'")
-		os.Exit(1)
+		fmt.Println("failed to load test env k8s binary, skipping the tests using the k8 cli. " +
+			"Make sure binaries are downloaded with 'make setup-envtest'")
+		os.Exit(m.Run())
 	}
-	testEnv.BinaryAssetsDirectory = binaryAssetsDir()
 
-	if err := cachev1alpha1.AddToScheme(scheme.Scheme); err != nil {
-		fmt.Printf("failed to add scheme: %v\n", err)
-		os.Exit(1)
+	testEnv := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "..", "config", "crd", "bases")},
+		ErrorIfCRDPathMissing: true,
+		BinaryAssetsDirectory: binaryAssetsDir(),
 	}
 
 	cfg, err := testEnv.Start()
@@ -62,8 +65,6 @@ func TestMain(m *testing.M) {
 		os.Exit(1)
 	}
 
-	tnn, pod = tnnAndPod("non-existing-pod", "non-existing-ns")
-
 	code := m.Run()
 
 	if err = testEnv.Stop(); err != nil {
@@ -74,6 +75,15 @@ func TestMain(m *testing.M) {
 	os.Exit(code)
 }
 
+// requireEnvtest skips the test if the k8 cli of the test environment is
+// missing.
+func requireEnvtest(t *testing.T) {
+	t.Helper()
+	if k8TestCli == nil {
+		t.Skip("skipping, the test env k8s binaries are missing")
+	}
+}
+
 func binaryAssetsDir() string {
 	basePath := filepath.Join("..", "..", "..", "bin", "k8s")
 	entries, err := os.ReadDir(basePath)
@@ -120,12 +130,26 @@ func newK8Cli(stubErrors infra.StubErrors, cliType string) *infra.K8CliImpl {
 	}
 }
 
+// k8Methods are the methods of the k8 cli with configurable stub responses.
+var k8Methods = []string{
+	"Get", "StatusUpdate", "Create", "Update", "List", "Delete", "DeleteAllOf", "Patch", "StatusPatch", "Apply",
+}
+
 type options struct {
 	cmd        string
 	cliType    string
 	stubErrors infra.StubErrors
 	pod        *corev1.Pod
 	tnn        *types.NamespacedName
+	// pods receives the result of 'List', the pods are listed in the namespace
+	// and with the labels of pod
+	pods *corev1.PodList
+	// patch is used by 'Patch' and 'StatusPatch', defaults to a merge patch
+	// sending the whole pod
+	patch client.Patch
+	// k8 is used instead of a new k8 cli of cliType, the object store of a stub
+	// keeps its objects across commands
+	k8 *infra.K8CliImpl
 }
 
 func runK8Cli(ctx context.Context, opt options) error {
@@ -133,7 +157,10 @@ func runK8Cli(ctx context.Context, opt options) error {
 		panic(fmt.Errorf("pod must be provided"))
 	}
 
-	k8 := newK8Cli(opt.stubErrors, opt.cliType)
+	k8 := opt.k8
+	if k8 == nil {
+		k8 = newK8Cli(opt.stubErrors, opt.cliType)
+	}
 
 	switch opt.cmd {
 	case "Get":
@@ -147,33 +174,58 @@ func runK8Cli(ctx context.Context, opt options) error {
 		return k8.Create(ctx, opt.pod)
 	case "Update":
 		return k8.Update(ctx, opt.pod)
+	case "Apply":
+		return k8.Apply(ctx, opt.pod, client.FieldOwner("infra-test"), client.ForceOwnership)
+	case "List":
+		if opt.pods == nil {
+			panic(fmt.Errorf("provide a PodList when using 'List'"))
+		}
+		return k8.List(ctx, opt.pods, client.InNamespace(opt.pod.Namespace), client.MatchingLabels(opt.pod.Labels))
+	case "Delete":
+		return k8.Delete(ctx, opt.pod)
+	case "DeleteAllOf":
+		return k8.DeleteAllOf(ctx, &corev1.Pod{}, client.InNamespace(opt.pod.Namespace), client.MatchingLabels(opt.pod.Labels))
+	case "Patch":
+		return k8.Patch(ctx, opt.pod, patchOrDefault(opt.patch))
+	case "StatusPatch":
+		return k8.StatusPatch(ctx, opt.pod, patchOrDefault(opt.patch))
 	default:
 		panic(fmt.Errorf("unknown command: %s", opt.cmd))
 	}
 }
 
+func patchOrDefault(patch client.Patch) client.Patch {
+	if patch != nil {
+		return patch
+	}
+	return client.Merge
+}
+
+// The deprecated helpers send a copy of the pod, writes to the object store of
+// the stub would change it for the following tests.
+
 // deprecated
 func k8Get(stubErrors infra.StubErrors, cliType string) error {
 	k8 := newK8Cli(stubErrors, cliType)
-	return k8.Get(ctx, tnn, pod)
+	return k8.Get(ctx, tnn, pod.DeepCopy())
 }
 
 // deprecated
 func k8StatusUpdate(stubErrors infra.StubErrors, cliType string) error {
 	k8 := newK8Cli(stubErrors, cliType)
-	return k8.StatusUpdate(ctx, pod)
+	return k8.StatusUpdate(ctx, pod.DeepCopy())
 }
 
 // deprecated
 func k8Create(stubErrors infra.StubErrors, cliType string) error {
 	k8 := newK8Cli(stubErrors, cliType)
-	return k8.Create(ctx, pod)
+	return k8.Create(ctx, pod.DeepCopy())
 }
 
 // deprecated
 func k8Update(stubErrors infra.StubErrors, cliType string) error {
 	k8 := newK8Cli(stubErrors, cliType)
-	return k8.Update(ctx, pod)
+	return k8.Update(ctx, pod.DeepCopy())
 }
 
 func createStubErrors(n int) infra.StubErrors {
@@ -3,10 +3,21 @@ package infra_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"example.com/m/v2/internal/controller/infra"
+	"github.com/go-logr/logr/funcr"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 func Test_K8Cli_stubWithConfigurableResponses(t *testing.T) {
@@ -65,46 +76,37 @@ func Test_K8Cli_stubWithMultiErrors(t *testing.T) {
 		t.Errorf("expected %v, got nothing", stubErrors["Get"][1])
 	}
 
-	if err := k8Get(stubErrors, "stub"); err != nil {
-		t.Errorf("expected nil, got %v", err)
-	}
+	// then the empty object store answers
+	assertNotFound(t, k8Get(stubErrors, "stub"))
 }
 
+// Without configured errors the empty object store of each stub answers: the
+// pod is created but can't be read or updated.
 func Test_K8Cli_stubWithNilResponses(t *testing.T) {
 	testCases := []struct {
-		name          string
-		stubErrors    infra.StubErrors
-		expectedError error
+		name       string
+		stubErrors infra.StubErrors
 	}{{
-		name:          "no errors provided for stub",
-		expectedError: nil,
+		name: "no errors provided for stub",
 	}, {
-		name:          "error for non existing method name",
-		stubErrors:    infra.StubErrors{"NonExisting": {errors.New("NonExisting error")}},
-		expectedError: nil,
+		name:       "error for non existing method name",
+		stubErrors: infra.StubErrors{"NonExisting": {errors.New("NonExisting error")}},
 	}, {
-		name:          "nil error",
-		stubErrors:    createStubErrors(0),
-		expectedError: nil,
+		name:       "nil error",
+		stubErrors: createStubErrors(0),
 	}}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			if err := k8Get(tc.stubErrors, "stub"); err != tc.expectedError {
-				t.Errorf("expected %v, got %v", tc.expectedError, err)
-			}
+			assertNotFound(t, k8Get(tc.stubErrors, "stub"))
 
-			if err := k8StatusUpdate(tc.stubErrors, "stub"); err != tc.expectedError {
-				t.Errorf("expected %v, got %v", tc.expectedError, err)
-			}
+			assertNotFound(t, k8StatusUpdate(tc.stubErrors, "stub"))
 
-			if err := k8Create(tc.stubErrors, "stub"); err != tc.expectedError {
-				t.Errorf("expected %v, got %v", tc.expectedError, err)
+			if err := k8Create(tc.stubErrors, "stub"); err != nil {
+				t.Errorf("expected nil, got %v", err)
 			}
 
-			if err := k8Update(tc.stubErrors, "stub"); err != tc.expectedError {
-				t.Errorf("expected %v, got %v", tc.expectedError, err)
-			}
+			assertNotFound(t, k8Update(tc.stubErrors, "stub"))
 		})
 	}
 }
@@ -115,16 +117,12 @@ func Test_K8Cli_stubWithNilAfterError(t *testing.T) {
 	if err := k8Get(stubErrors, "stub"); err == nil {
 		t.Errorf("expected %v, got nothing", stubErrors["Get"])
 	}
-	if err := k8Get(stubErrors, "stub"); err != nil {
-		t.Errorf("expected nil, got %v", err)
-	}
+	assertNotFound(t, k8Get(stubErrors, "stub"))
 
 	if err := k8StatusUpdate(stubErrors, "stub"); err == nil {
 		t.Errorf("expected %v, got nothing", stubErrors["StatusUpdate"])
 	}
-	if err := k8StatusUpdate(stubErrors, "stub"); err != nil {
-		t.Errorf("expected nil, got %v", err)
-	}
+	assertNotFound(t, k8StatusUpdate(stubErrors, "stub"))
 
 	if err := k8Create(stubErrors, "stub"); err == nil {
 		t.Errorf("expected %v, got nothing", stubErrors["Create"])
@@ -136,12 +134,11 @@ func Test_K8Cli_stubWithNilAfterError(t *testing.T) {
 	if err := k8Update(stubErrors, "stub"); err == nil {
 		t.Errorf("expected %v, got nothing", stubErrors["Update"])
 	}
-	if err := k8Update(stubErrors, "stub"); err != nil {
-		t.Errorf("expected nil, got %v", err)
-	}
+	assertNotFound(t, k8Update(stubErrors, "stub"))
 }
 
 func Test_K8Cli_errorPropagation(t *testing.T) {
+	requireEnvtest(t)
 	testCases := []struct {
 		name    string
 		cliType string
@@ -171,6 +168,7 @@ func Test_K8Cli_errorPropagation(t *testing.T) {
 }
 
 func Test_K8Cli_commandPropagation(t *testing.T) {
+	requireEnvtest(t)
 	ctx := context.Background()
 
 	testCases := []struct {
@@ -231,3 +229,573 @@ func Test_K8Cli_commandPropagation(t *testing.T) {
 		})
 	}
 }
+
+func Test_K8Cli_applyPropagation(t *testing.T) {
+	requireEnvtest(t)
+	ctx := context.Background()
+
+	testCases := []struct {
+		name,
+		podName,
+		cliType string
+	}{{
+		name:    "actual implementation applies the object",
+		podName: "first-applied-pod",
+		cliType: "impl",
+	}, {
+		name:    "stub with real k8 cli applies the object",
+		podName: "second-applied-pod",
+		cliType: "stubWithK8",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tnn, pod := tnnAndPod(tc.podName, "default")
+			pod.APIVersion, pod.Kind = "v1", "Pod"
+			runOpt := options{cmd: "Apply", cliType: tc.cliType, pod: pod, tnn: &tnn}
+
+			// create
+			if err := runK8Cli(ctx, runOpt); err != nil {
+				t.Errorf("unexpected error applying pod %v", err)
+			}
+
+			// update the owned label
+			pod.Labels = map[string]string{"applied": "twice"}
+			if err := runK8Cli(ctx, runOpt); err != nil {
+				t.Errorf("unexpected error applying pod %v", err)
+			}
+
+			// get
+			runOpt.cmd = "Get"
+			got := &v1.Pod{}
+			runOpt.pod = got
+			if err := runK8Cli(ctx, runOpt); err != nil {
+				t.Errorf("unexpected error getting pod %v", err)
+			}
+			if got.Labels["applied"] != "twice" {
+				t.Errorf("expected label applied=twice, got %v", got.Labels)
+			}
+		})
+	}
+}
+
+func Test_K8Cli_stubApplyErrors(t *testing.T) {
+	expectedErr := errors.New("Apply error 1")
+	_, pod := tnnAndPod("stub-applied-pod", "default")
+	runOpt := options{cmd: "Apply", cliType: "stub", pod: pod, stubErrors: infra.StubErrors{"Apply": {expectedErr}}}
+
+	if err := runK8Cli(context.Background(), runOpt); err != expectedErr {
+		t.Errorf("expected %v, got %v", expectedErr, err)
+	}
+	if err := runK8Cli(context.Background(), runOpt); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+}
+
+func Test_K8Cli_stubErrorsForEveryMethod(t *testing.T) {
+	// after the error the empty object store answers, the pod is missing
+	readsPod := map[string]bool{
+		"Get": true, "StatusUpdate": true, "Update": true, "Delete": true, "Patch": true, "StatusPatch": true,
+	}
+	for _, method := range k8Methods {
+		t.Run(method, func(t *testing.T) {
+			expectedErr := fmt.Errorf("%s error 1", method)
+			tnn, pod := tnnAndPod("stub-pod", "default")
+			runOpt := options{
+				cmd:        method,
+				cliType:    "stub",
+				stubErrors: infra.StubErrors{method: {expectedErr}},
+				pod:        pod,
+				tnn:        &tnn,
+				pods:       &v1.PodList{},
+			}
+
+			if err := runK8Cli(context.Background(), runOpt); err != expectedErr {
+				t.Errorf("expected %v, got %v", expectedErr, err)
+			}
+			err := runK8Cli(context.Background(), runOpt)
+			if readsPod[method] {
+				assertNotFound(t, err)
+			} else if err != nil {
+				t.Errorf("expected nil, got %v", err)
+			}
+		})
+	}
+}
+
+func Test_K8Cli_patchAndDeleteErrorPropagation(t *testing.T) {
+	requireEnvtest(t)
+	for _, cliType := range []string{"impl", "stubWithK8"} {
+		t.Run(cliType, func(t *testing.T) {
+			for _, cmd := range []string{"Delete", "Patch", "StatusPatch"} {
+				err := runK8Cli(context.Background(), options{cmd: cmd, cliType: cliType, pod: pod.DeepCopy()})
+				assertNotFound(t, err)
+			}
+		})
+	}
+}
+
+func Test_K8Cli_listDeleteAndPatchPropagation(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := []struct {
+		name,
+		podName,
+		cliType string
+	}{{
+		name:    "actual implementation propagates k8 cli commands",
+		podName: "first-listed-pod",
+		cliType: "impl",
+	}, {
+		name:    "stub with real k8 cli propagates k8 cli commands",
+		podName: "second-listed-pod",
+		cliType: "stubWithK8",
+	}, {
+		name:    "stub answers k8 cli commands from its object store",
+		podName: "third-listed-pod",
+		cliType: "stub",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.cliType != "stub" {
+				requireEnvtest(t)
+			}
+			tnn, pod := tnnAndPod(tc.podName, "default")
+			pod.Labels = map[string]string{"listed": tc.podName}
+			runOpt := options{cmd: "Create", cliType: tc.cliType, pod: pod, tnn: &tnn, pods: &v1.PodList{}}
+			if tc.cliType == "stub" {
+				runOpt.k8 = newK8Cli(nil, tc.cliType)
+			}
+			if err := runK8Cli(ctx, runOpt); err != nil {
+				t.Fatalf("unexpected error creating pod %v", err)
+			}
+
+			// patch
+			runOpt.cmd, runOpt.patch = "Patch", client.MergeFrom(pod.DeepCopy())
+			pod.Annotations = map[string]string{"patched": "true"}
+			if err := runK8Cli(ctx, runOpt); err != nil {
+				t.Errorf("unexpected error patching pod %v", err)
+			}
+
+			// status patch
+			runOpt.cmd, runOpt.patch = "StatusPatch", client.MergeFrom(pod.DeepCopy())
+			pod.Status.Phase = v1.PodRunning
+			if err := runK8Cli(ctx, runOpt); err != nil {
+				t.Errorf("unexpected error patching pod status %v", err)
+			}
+
+			// list
+			runOpt.cmd = "List"
+			if err := runK8Cli(ctx, runOpt); err != nil {
+				t.Errorf("unexpected error listing pods %v", err)
+			}
+			if len(runOpt.pods.Items) != 1 {
+				t.Fatalf("expected 1 pod, got %d", len(runOpt.pods.Items))
+			}
+			if got := runOpt.pods.Items[0]; got.Annotations["patched"] != "true" || got.Status.Phase != v1.PodRunning {
+				t.Errorf("expected patched pod, got annotations %v and phase %s", got.Annotations, got.Status.Phase)
+			}
+
+			// delete
+			runOpt.cmd = "Delete"
+			if err := runK8Cli(ctx, runOpt); err != nil {
+				t.Errorf("unexpected error deleting pod %v", err)
+			}
+			runOpt.cmd = "Get"
+			assertNotFound(t, runK8Cli(ctx, runOpt))
+
+			// delete all of
+			pod.ResourceVersion = ""
+			runOpt.cmd = "Create"
+			if err := runK8Cli(ctx, runOpt); err != nil {
+				t.Fatalf("unexpected error creating pod %v", err)
+			}
+			runOpt.cmd = "DeleteAllOf"
+			if err := runK8Cli(ctx, runOpt); err != nil {
+				t.Errorf("unexpected error deleting all pods %v", err)
+			}
+			runOpt.cmd = "List"
+			if err := runK8Cli(ctx, runOpt); err != nil {
+				t.Errorf("unexpected error listing pods %v", err)
+			}
+			if len(runOpt.pods.Items) != 0 {
+				t.Errorf("expected no pods, got %d", len(runOpt.pods.Items))
+			}
+		})
+	}
+}
+
+func Test_K8Cli_tracksWrites(t *testing.T) {
+	ctx := context.Background()
+	k8 := newK8Cli(nil, "stub")
+	tnn, pod := tnnAndPod("tracked-pod", "default")
+
+	tracker := k8.TrackWrites()
+	_ = k8.Get(ctx, tnn, pod)
+	_ = k8.Create(ctx, pod)
+	pod.Spec.Containers[0].Image = "ubuntu"
+	_ = k8.Update(ctx, pod)
+	pod.Status.Phase = v1.PodRunning
+	_ = k8.StatusUpdate(ctx, pod)
+	_ = k8.Patch(ctx, pod, client.Merge)
+	_ = k8.StatusPatch(ctx, pod, client.Merge)
+	_ = k8.Apply(ctx, pod)
+	_ = k8.Delete(ctx, pod)
+	_ = k8.DeleteAllOf(ctx, &v1.Pod{}, client.InNamespace("default"))
+
+	writes := tracker.Data()
+	var actions []string
+	for _, write := range writes {
+		actions = append(actions, write.Action)
+	}
+	expected := []string{"Create", "Update", "StatusUpdate", "Patch", "StatusPatch", "Apply", "Delete", "DeleteAllOf"}
+	if !reflect.DeepEqual(actions, expected) {
+		t.Fatalf("expected %v, got %v", expected, actions)
+	}
+
+	created := writes[0].Object.(*v1.Pod)
+	if created.Spec.Containers[0].Image != "busybox" || created.Status.Phase != "" {
+		t.Errorf("expected a copy of the created pod, got image %s and phase %s",
+			created.Spec.Containers[0].Image, created.Status.Phase)
+	}
+	if updated := writes[1].Object.(*v1.Pod); updated.Spec.Containers[0].Image != "ubuntu" {
+		t.Errorf("expected updated image ubuntu, got %s", updated.Spec.Containers[0].Image)
+	}
+}
+
+func Test_K8Cli_doesNotTrackFailedWrites(t *testing.T) {
+	ctx := context.Background()
+	k8 := newK8Cli(infra.StubErrors{"Create": {errors.New("Create error 1")}}, "stub")
+	_, pod := tnnAndPod("failed-pod", "default")
+	tracker := k8.TrackWrites()
+
+	if err := k8.Create(ctx, pod); err == nil {
+		t.Fatal("expected error, got nothing")
+	}
+	if got := tracker.Data(); len(got) != 0 {
+		t.Errorf("expected no writes, got %v", got)
+	}
+
+	if err := k8.Create(ctx, pod); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got := tracker.Data(); len(got) != 1 {
+		t.Errorf("expected 1 write, got %v", got)
+	}
+}
+
+func Test_K8Cli_stubWithObjectResponses(t *testing.T) {
+	ctx := context.Background()
+	tnn, _ := tnnAndPod("configured-pod", "default")
+	_, configured := tnnAndPod("configured-pod", "default")
+	configured.Spec.Containers[0].Image = "ubuntu"
+	expectedErr := errors.New("Get error 1")
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{
+		Responses: infra.StubResponses{
+			{Method: "Get", GVK: v1.SchemeGroupVersion.WithKind("Pod"), Key: tnn}: {
+				{Err: expectedErr},
+				{Object: configured},
+			},
+		},
+	})
+
+	// the error first
+	got := &v1.Pod{}
+	if err := k8.Get(ctx, tnn, got); err != expectedErr {
+		t.Errorf("expected %v, got %v", expectedErr, err)
+	}
+
+	// then the object
+	if err := k8.Get(ctx, tnn, got); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, configured) {
+		t.Errorf("expected %v, got %v", configured, got)
+	}
+	got.Spec.Containers[0].Image = "fedora"
+	if configured.Spec.Containers[0].Image != "ubuntu" {
+		t.Errorf("expected a copy of the configured pod, got the configured pod")
+	}
+
+	// then the empty object store
+	got = &v1.Pod{}
+	assertNotFound(t, k8.Get(ctx, tnn, got))
+	if got.Name != "" {
+		t.Errorf("expected empty pod, got %v", got)
+	}
+}
+
+func Test_K8Cli_stubWithResponsesForOtherObjects(t *testing.T) {
+	ctx := context.Background()
+	tnn, configured := tnnAndPod("configured-pod", "default")
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{
+		Responses: infra.StubResponses{
+			{Method: "Get", GVK: v1.SchemeGroupVersion.WithKind("Pod"), Key: tnn}: {{Object: configured}},
+		},
+	})
+
+	otherTnn, _ := tnnAndPod("other-pod", "default")
+	got := &v1.Pod{}
+	assertNotFound(t, k8.Get(ctx, otherTnn, got))
+	if got.Name != "" {
+		t.Errorf("expected empty pod for other name, got %v", got)
+	}
+	assertNotFound(t, k8.Get(ctx, tnn, &v1.Service{}))
+	if err := k8.Get(ctx, tnn, got); err != nil || got.Name != tnn.Name {
+		t.Errorf("expected configured pod, got %v and %v", got, err)
+	}
+}
+
+func Test_K8Cli_stubWithListResponses(t *testing.T) {
+	ctx := context.Background()
+	_, first := tnnAndPod("first-pod", "default")
+	_, second := tnnAndPod("second-pod", "default")
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{
+		Responses: infra.StubResponses{
+			{Method: "List", GVK: v1.SchemeGroupVersion.WithKind("PodList"), Key: types.NamespacedName{Namespace: "default"}}: {
+				{Object: &v1.PodList{Items: []v1.Pod{*first, *second}}},
+			},
+		},
+	})
+
+	other := &v1.PodList{}
+	if err := k8.List(ctx, other, client.InNamespace("other")); err != nil || len(other.Items) != 0 {
+		t.Errorf("expected no pods in other namespace, got %v and %v", other.Items, err)
+	}
+
+	pods := &v1.PodList{}
+	if err := k8.List(ctx, pods, client.InNamespace("default")); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if len(pods.Items) != 2 {
+		t.Errorf("expected 2 pods, got %d", len(pods.Items))
+	}
+}
+
+func Test_K8Cli_stubWithResponsesForAllObjects(t *testing.T) {
+	expectedErr := errors.New("Create error 1")
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{
+		Responses: infra.StubResponses{
+			{Method: "Create"}: {{Err: expectedErr}},
+		},
+	})
+	_, pod := tnnAndPod("any-pod", "default")
+
+	if err := k8.Create(context.Background(), pod); err != expectedErr {
+		t.Errorf("expected %v, got %v", expectedErr, err)
+	}
+	if err := k8.Create(context.Background(), pod); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+}
+
+func Test_K8Cli_stubWithResponseOfWrongType(t *testing.T) {
+	tnn, _ := tnnAndPod("wrong-pod", "default")
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{
+		Responses: infra.StubResponses{
+			{Method: "Get", GVK: v1.SchemeGroupVersion.WithKind("Pod"), Key: tnn}: {{Object: &v1.Service{}}},
+		},
+	})
+
+	if err := k8.Get(context.Background(), tnn, &v1.Pod{}); err == nil {
+		t.Error("expected error, got nothing")
+	}
+}
+
+func Test_K8Cli_stubWithFaultsForOneObject(t *testing.T) {
+	ctx := context.Background()
+	tnn, pod := tnnAndPod("faulty-pod", "default")
+	expectedErr := errors.New("Update error")
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{
+		Faults: infra.StubFaults{
+			{Method: "Update", GVK: v1.SchemeGroupVersion.WithKind("Pod"), Name: tnn.Name, Err: expectedErr},
+		},
+		Objects: []client.Object{pod},
+	})
+
+	got := &v1.Pod{}
+	if err := k8.Get(ctx, tnn, got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := k8.StatusUpdate(ctx, got); err != nil {
+		t.Errorf("expected StatusUpdate to pass, got %v", err)
+	}
+	if err := k8.Update(ctx, got); err != expectedErr {
+		t.Errorf("expected %v, got %v", expectedErr, err)
+	}
+	if err := k8.Update(ctx, got); err != expectedErr {
+		t.Errorf("expected %v for every call, got %v", expectedErr, err)
+	}
+
+	_, other := tnnAndPod("other-pod", "default")
+	if err := k8.Create(ctx, other); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := k8.Update(ctx, other); err != nil {
+		t.Errorf("expected Update of other pod to pass, got %v", err)
+	}
+	dep := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: tnn.Name, Namespace: tnn.Namespace}}
+	if err := k8.Create(ctx, dep); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := k8.Update(ctx, dep); err != nil {
+		t.Errorf("expected Update of other kind to pass, got %v", err)
+	}
+}
+
+func Test_K8Cli_stubWithFaultForNthCall(t *testing.T) {
+	ctx := context.Background()
+	tnn, pod := tnnAndPod("third-pod", "default")
+	expectedErr := errors.New("Get error 3")
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{
+		Faults: infra.StubFaults{
+			{Method: "Get", Call: 3, Err: expectedErr},
+		},
+		Objects: []client.Object{pod},
+	})
+
+	for call := 1; call <= 4; call++ {
+		err := k8.Get(ctx, tnn, &v1.Pod{})
+		if call == 3 && err != expectedErr {
+			t.Errorf("expected %v for call %d, got %v", expectedErr, call, err)
+		}
+		if call != 3 && err != nil {
+			t.Errorf("unexpected error %v for call %d", err, call)
+		}
+	}
+}
+
+func Test_K8Cli_stubWithFaultForNamespace(t *testing.T) {
+	ctx := context.Background()
+	expectedErr := errors.New("List error")
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{
+		Faults: infra.StubFaults{
+			{Method: "List", GVK: v1.SchemeGroupVersion.WithKind("PodList"), Namespace: "broken", Err: expectedErr},
+		},
+	})
+
+	if err := k8.List(ctx, &v1.PodList{}, client.InNamespace("broken")); err != expectedErr {
+		t.Errorf("expected %v, got %v", expectedErr, err)
+	}
+	if err := k8.List(ctx, &v1.PodList{}, client.InNamespace("default")); err != nil {
+		t.Errorf("expected List of other namespace to pass, got %v", err)
+	}
+	if err := k8.List(ctx, &v1.ServiceList{}, client.InNamespace("broken")); err != nil {
+		t.Errorf("expected List of other kind to pass, got %v", err)
+	}
+}
+
+func Test_K8Cli_stubWithFaultLatency(t *testing.T) {
+	tnn, pod := tnnAndPod("slow-pod", "default")
+	latency := 50 * time.Millisecond
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{
+		Faults: infra.StubFaults{
+			{Method: "Get", Latency: latency},
+		},
+		Objects: []client.Object{pod},
+	})
+
+	start := time.Now()
+	if err := k8.Get(context.Background(), tnn, &v1.Pod{}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < latency {
+		t.Errorf("expected Get to take at least %v, took %v", latency, elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := k8.Get(ctx, tnn, &v1.Pod{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func Test_K8Cli_stubCombinesFaultsWithResponses(t *testing.T) {
+	ctx := context.Background()
+	tnn, configured := tnnAndPod("combined-pod", "default")
+	latency := 50 * time.Millisecond
+	expectedErr := errors.New("Get error 2")
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{
+		Faults: infra.StubFaults{
+			{Method: "Get", Latency: latency},
+			{Method: "Get", Call: 2, Err: expectedErr},
+		},
+		Responses: infra.StubResponses{
+			{Method: "Get", GVK: v1.SchemeGroupVersion.WithKind("Pod"), Key: tnn}: {{Object: configured}},
+		},
+	})
+
+	start := time.Now()
+	got := &v1.Pod{}
+	if err := k8.Get(ctx, tnn, got); err != nil || got.Name != tnn.Name {
+		t.Errorf("expected the configured pod, got %v and %v", got, err)
+	}
+	if elapsed := time.Since(start); elapsed < latency {
+		t.Errorf("expected the response after at least %v, got %v", latency, elapsed)
+	}
+	if err := k8.Get(ctx, tnn, &v1.Pod{}); err != expectedErr {
+		t.Errorf("expected %v, got %v", expectedErr, err)
+	}
+}
+
+func Test_K8Cli_stubLogsDiagnostics(t *testing.T) {
+	var logged []string
+	log := funcr.New(func(prefix, args string) {
+		logged = append(logged, prefix+" "+args)
+	}, funcr.Options{Verbosity: 1})
+	ctx := logf.IntoContext(context.Background(), log)
+	tnn, _ := tnnAndPod("logged-pod", "default")
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{Errors: infra.StubErrors{"Create": {}}})
+
+	assertNotFound(t, k8.Get(ctx, tnn, &v1.Pod{}))
+
+	if len(logged) != 1 || !strings.Contains(logged[0], "no more responses configured") ||
+		!strings.Contains(logged[0], `"method"="Get"`) {
+		t.Errorf("expected diagnostics for the Get, got %v", logged)
+	}
+}
+
+// Run with -race to detect unsynchronized access to the responses.
+func Test_K8Cli_stubIsSafeForConcurrentUse(t *testing.T) {
+	ctx := context.Background()
+	const calls = 50
+	errs := make([]error, calls)
+	for i := range errs {
+		errs[i] = fmt.Errorf("Create error %d", i)
+	}
+	k8 := infra.NewK8CliStub(infra.StubErrors{"Create": errs}, nil)
+	tracker := k8.TrackWrites()
+
+	var wg sync.WaitGroup
+	failed := make(chan error, 2*calls)
+	for i := 0; i < 2*calls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, pod := tnnAndPod(fmt.Sprintf("concurrent-pod-%d", i), "default")
+			if err := k8.Create(ctx, pod); err != nil {
+				failed <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(failed)
+
+	// every configured error is returned exactly once
+	returned := map[error]int{}
+	for err := range failed {
+		returned[err]++
+	}
+	for _, err := range errs {
+		if returned[err] != 1 {
+			t.Errorf("expected %q to be returned once, got %d times", err, returned[err])
+		}
+	}
+	if len(returned) != calls {
+		t.Errorf("expected %d distinct errors, got %d", calls, len(returned))
+	}
+	if got := len(tracker.Data()); got != calls {
+		t.Errorf("expected %d tracked writes, got %d", calls, got)
+	}
+}
@@ -0,0 +1,82 @@
+package infra
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// unknownLabel is the label value of a kind which isn't in the scheme and of
+// an error without a status reason, e.g. a timeout of the connection.
+const unknownLabel = "Unknown"
+
+// k8RequestDuration measures the latency of the requests of the K8CliImpl. The
+// requests of the Embedded Stub are measured as well, they take no time unless
+// a fault adds latency.
+var k8RequestDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "memcached_k8_request_duration_seconds",
+		Help:    "Latency of the requests of the operator to the API server by method and kind.",
+		Buckets: prometheus.DefBuckets,
+	},
+	[]string{"method", "group", "version", "kind"},
+)
+
+// k8RequestErrorsTotal counts the failed requests of the K8CliImpl by the
+// reason of the API status, e.g. NotFound or Conflict.
+var k8RequestErrorsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "memcached_k8_request_errors_total",
+		Help: "Number of failed requests of the operator to the API server by method, kind and status reason.",
+	},
+	[]string{"method", "group", "version", "kind", "reason"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(k8RequestDuration, k8RequestErrorsTotal)
+}
+
+// observe runs the request and records its latency and error.
+func (k8 *K8CliImpl) observe(method string, obj runtime.Object, request func() error) error {
+	gvk := k8.gvkFor(obj)
+	start := time.Now()
+	err := request()
+
+	k8RequestDuration.WithLabelValues(method, gvk.Group, gvk.Version, gvk.Kind).
+		Observe(time.Since(start).Seconds())
+	if err != nil {
+		k8RequestErrorsTotal.WithLabelValues(method, gvk.Group, gvk.Version, gvk.Kind, reasonFor(err)).Inc()
+	}
+
+	return err
+}
+
+// gvkFor returns the GVK of the object. Lists are recorded with the kind of
+// their items, e.g. Pod for a PodList.
+func (k8 *K8CliImpl) gvkFor(obj runtime.Object) schema.GroupVersionKind {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Empty() && k8.scheme != nil {
+		gvk, _ = apiutil.GVKForObject(obj, k8.scheme)
+	}
+	if gvk.Kind == "" {
+		return schema.GroupVersionKind{Kind: unknownLabel}
+	}
+	if meta.IsListType(obj) {
+		gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	}
+	return gvk
+}
+
+func reasonFor(err error) string {
+	if reason := apierrors.ReasonForError(err); reason != "" {
+		return string(reason)
+	}
+	return unknownLabel
+}
@@ -0,0 +1,177 @@
+package infra_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"example.com/m/v2/internal/controller/infra"
+	dto "github.com/prometheus/client_model/go"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	durationMetric = "memcached_k8_request_duration_seconds"
+	errorsMetric   = "memcached_k8_request_errors_total"
+)
+
+func Test_Metrics_recordsLatencyByMethodAndKind(t *testing.T) {
+	ctx := context.Background()
+	tnn, pod := tnnAndPod("measured-pod", "default")
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{Objects: []client.Object{pod}})
+	getPod := requestLabels("Get", "", "Pod")
+	listPods := requestLabels("List", "", "Pod")
+	applyDeployment := requestLabels("Apply", "apps", "Deployment")
+	gets, lists, applies := sampleCount(t, getPod), sampleCount(t, listPods), sampleCount(t, applyDeployment)
+
+	if err := k8.Get(ctx, tnn, &corev1.Pod{}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := k8.List(ctx, &corev1.PodList{}, client.InNamespace("default")); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	dep := &appsv1.Deployment{}
+	dep.SetGroupVersionKind(appsv1.SchemeGroupVersion.WithKind("Deployment"))
+	dep.Name, dep.Namespace = "measured-deployment", "default"
+	if err := k8.Apply(ctx, dep); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if got := sampleCount(t, getPod); got != gets+1 {
+		t.Errorf("expected %d measured Gets of Pods, got %d", gets+1, got)
+	}
+	if got := sampleCount(t, listPods); got != lists+1 {
+		t.Errorf("expected %d measured Lists of Pods, got %d", lists+1, got)
+	}
+	if got := sampleCount(t, applyDeployment); got != applies+1 {
+		t.Errorf("expected %d measured Applies of Deployments, got %d", applies+1, got)
+	}
+}
+
+func Test_Metrics_recordsLatencyOfFaults(t *testing.T) {
+	_, pod := tnnAndPod("slow-measured-pod", "default")
+	latency := 50 * time.Millisecond
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{
+		Faults: infra.StubFaults{
+			{Method: "Create", Latency: latency},
+		},
+	})
+	createPod := requestLabels("Create", "", "Pod")
+	before := sampleSum(t, createPod)
+
+	if err := k8.Create(context.Background(), pod); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if got := sampleSum(t, createPod) - before; got < latency.Seconds() {
+		t.Errorf("expected a latency of at least %v, got %vs", latency, got)
+	}
+}
+
+func Test_Metrics_recordsErrorsByReason(t *testing.T) {
+	ctx := context.Background()
+	tnn, pod := tnnAndPod("failing-measured-pod", "default")
+	conflict := apierrors.NewConflict(corev1.Resource("pods"), tnn.Name, errors.New("modified"))
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{
+		Errors: infra.StubErrors{
+			"Update": {conflict, errors.New("connection reset")},
+		},
+		Objects: []client.Object{pod},
+	})
+	notFound := errorLabels("Get", "", "Pod", "NotFound")
+	conflicts := errorLabels("Update", "", "Pod", "Conflict")
+	unknown := errorLabels("Update", "", "Pod", "Unknown")
+	before := map[string]float64{}
+	for name, labels := range map[string]map[string]string{"notFound": notFound, "conflicts": conflicts, "unknown": unknown} {
+		before[name] = counterValue(t, labels)
+	}
+
+	missing := types.NamespacedName{Name: "missing-pod", Namespace: "default"}
+	if err := k8.Get(ctx, missing, &corev1.Pod{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+	_ = k8.Update(ctx, pod)
+	_ = k8.Update(ctx, pod)
+	if err := k8.Get(ctx, tnn, &corev1.Pod{}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	for name, labels := range map[string]map[string]string{"notFound": notFound, "conflicts": conflicts, "unknown": unknown} {
+		if got := counterValue(t, labels); got != before[name]+1 {
+			t.Errorf("expected %v %s errors, got %v", before[name]+1, labels["reason"], got)
+		}
+	}
+}
+
+func requestLabels(method, group, kind string) map[string]string {
+	return map[string]string{"method": method, "group": group, "version": "v1", "kind": kind}
+}
+
+func errorLabels(method, group, kind, reason string) map[string]string {
+	labels := requestLabels(method, group, kind)
+	labels["reason"] = reason
+	return labels
+}
+
+func sampleCount(t *testing.T, labels map[string]string) uint64 {
+	t.Helper()
+	if m := findMetric(t, durationMetric, labels); m != nil {
+		return m.GetHistogram().GetSampleCount()
+	}
+	return 0
+}
+
+func sampleSum(t *testing.T, labels map[string]string) float64 {
+	t.Helper()
+	if m := findMetric(t, durationMetric, labels); m != nil {
+		return m.GetHistogram().GetSampleSum()
+	}
+	return 0
+}
+
+func counterValue(t *testing.T, labels map[string]string) float64 {
+	t.Helper()
+	if m := findMetric(t, errorsMetric, labels); m != nil {
+		return m.GetCounter().GetValue()
+	}
+	return 0
+}
+
+// findMetric returns the metric with exactly the labels from the
+// controller-runtime registry, nil if nothing was recorded for them yet.
+func findMetric(t *testing.T, name string, labels map[string]string) *dto.Metric {
+	t.Helper()
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			if hasLabels(m, labels) {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+func hasLabels(m *dto.Metric, labels map[string]string) bool {
+	if len(m.GetLabel()) != len(labels) {
+		return false
+	}
+	for _, label := range m.GetLabel() {
+		if labels[label.GetName()] != label.GetValue() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package infra
+
+import (
+	"context"
+	"reflect"
+
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// conflictBackoff is the backoff between the retries of StatusUpdateWithRetry,
+// it gives up after 5 attempts.
+var conflictBackoff = retry.DefaultRetry
+
+// Mutate changes the object before it's written. It's called again after the
+// object was read anew, so it must apply all changes, not only the missing ones.
+type Mutate func() error
+
+// StatusUpdateWithRetry applies mutate to the object and updates its status. If
+// the update fails with a 409 Conflict because the object has been modified, it
+// reads the object again and retries with the conflictBackoff until the context
+// is done. Other errors, including the errors of mutate, are returned
+// immediately.
+func (k8 *K8CliImpl) StatusUpdateWithRetry(ctx context.Context, co client.Object, mutate Mutate) error {
+	return k8.retryOnConflict(ctx, co, mutate, k8.StatusUpdate)
+}
+
+func (k8 *K8CliImpl) retryOnConflict(
+	ctx context.Context,
+	co client.Object,
+	mutate Mutate,
+	write func(context.Context, client.Object) error,
+) error {
+	key := client.ObjectKeyFromObject(co)
+	attempt := 0
+
+	return retry.RetryOnConflict(conflictBackoff, func() error {
+		attempt++
+		if attempt > 1 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			logf.FromContext(ctx).V(1).Info("conflict, reading the object again", "key", key, "attempt", attempt)
+			if err := k8.reread(ctx, key, co); err != nil {
+				return err
+			}
+		}
+		if err := mutate(); err != nil {
+			return err
+		}
+		return write(ctx, co)
+	})
+}
+
+// reread reads the object into a new object and copies it over the stale one,
+// reading into the stale object would merge into its fields, e.g. its labels.
+// The stale object is kept if the read fails.
+func (k8 *K8CliImpl) reread(ctx context.Context, key client.ObjectKey, co client.Object) error {
+	fresh := reflect.New(reflect.TypeOf(co).Elem()).Interface().(client.Object)
+	if err := k8.Get(ctx, key, fresh); err != nil {
+		return err
+	}
+	return copyInto(co, fresh)
+}
@@ -0,0 +1,158 @@
+package infra_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"example.com/m/v2/internal/controller/infra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var podGVK = corev1.SchemeGroupVersion.WithKind("Pod")
+
+func Test_Retry_rereadsStaleObject(t *testing.T) {
+	ctx := context.Background()
+	tnn, pod := tnnAndPod("stale-pod", "default")
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{Objects: []client.Object{pod}})
+
+	stale, other := &corev1.Pod{}, &corev1.Pod{}
+	if err := k8.Get(ctx, tnn, stale); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := k8.Get(ctx, tnn, other); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	other.Labels = map[string]string{"other": "writer"}
+	if err := k8.Update(ctx, other); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	mutations := 0
+	err := k8.StatusUpdateWithRetry(ctx, stale, func() error {
+		mutations++
+		stale.Status.Message = "retried"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if mutations != 2 {
+		t.Errorf("expected 2 mutations, got %d", mutations)
+	}
+
+	got := &corev1.Pod{}
+	if err := k8.Get(ctx, tnn, got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got.Labels["other"] != "writer" || got.Status.Message != "retried" {
+		t.Errorf("expected the changes of both writers, got %v and %q", got.Labels, got.Status.Message)
+	}
+}
+
+func Test_Retry_injectedConflict(t *testing.T) {
+	ctx := context.Background()
+	tnn, pod := tnnAndPod("conflicting-pod", "default")
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{
+		Faults: infra.StubFaults{
+			infra.ConflictFault("StatusUpdate", podGVK, tnn, 1),
+		},
+		Objects: []client.Object{pod},
+	})
+	tracker := k8.TrackWrites()
+
+	got := &corev1.Pod{}
+	if err := k8.Get(ctx, tnn, got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	mutations := 0
+	err := k8.StatusUpdateWithRetry(ctx, got, func() error {
+		mutations++
+		got.Status.Message = "retried"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if mutations != 2 {
+		t.Errorf("expected 2 mutations, got %d", mutations)
+	}
+	if writes := tracker.Data(); len(writes) != 1 || writes[0].Action != "StatusUpdate" {
+		t.Errorf("expected 1 StatusUpdate, got %v", writes)
+	}
+}
+
+func Test_Retry_givesUp(t *testing.T) {
+	ctx := context.Background()
+	tnn, pod := tnnAndPod("always-conflicting-pod", "default")
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{
+		Faults: infra.StubFaults{
+			infra.ConflictFault("StatusUpdate", podGVK, tnn, 0),
+		},
+		Objects: []client.Object{pod},
+	})
+
+	mutations := 0
+	err := k8.StatusUpdateWithRetry(ctx, pod, func() error {
+		mutations++
+		return nil
+	})
+	if !apierrors.IsConflict(err) {
+		t.Errorf("expected Conflict, got %v", err)
+	}
+	if mutations != 5 {
+		t.Errorf("expected 5 attempts, got %d", mutations)
+	}
+}
+
+func Test_Retry_stopsWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	tnn, pod := tnnAndPod("cancelled-pod", "default")
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{
+		Faults: infra.StubFaults{
+			infra.ConflictFault("StatusUpdate", podGVK, tnn, 0),
+		},
+		Objects: []client.Object{pod},
+	})
+
+	mutations := 0
+	err := k8.StatusUpdateWithRetry(ctx, pod, func() error {
+		mutations++
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+	if mutations != 1 {
+		t.Errorf("expected 1 attempt, got %d", mutations)
+	}
+}
+
+func Test_Retry_returnsOtherErrors(t *testing.T) {
+	ctx := context.Background()
+	_, pod := tnnAndPod("failing-pod", "default")
+	updateErr, mutateErr := errors.New("StatusUpdate error"), errors.New("mutate error")
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{
+		Errors:  infra.StubErrors{"StatusUpdate": {updateErr}},
+		Objects: []client.Object{pod},
+	})
+
+	mutations := 0
+	err := k8.StatusUpdateWithRetry(ctx, pod, func() error {
+		mutations++
+		return nil
+	})
+	if err != updateErr || mutations != 1 {
+		t.Errorf("expected %v after 1 mutation, got %v after %d", updateErr, err, mutations)
+	}
+
+	err = k8.StatusUpdateWithRetry(ctx, pod, func() error {
+		return mutateErr
+	})
+	if err != mutateErr {
+		t.Errorf("expected %v, got %v", mutateErr, err)
+	}
+}
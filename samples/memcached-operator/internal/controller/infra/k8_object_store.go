@@ -0,0 +1,478 @@
+package infra
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// objectStore is the in-memory object store which answers the requests of the
+// Embedded Stub without a k8 client. It knows the types of the scheme and
+// behaves like an API server for the cases the reconcilers care about: objects
+// get a uid and a resourceVersion, stale writes fail with a conflict, missing
+// objects with NotFound and duplicates with AlreadyExists. Owner references and
+// finalizers are kept, deleting an object with finalizers sets its
+// deletionTimestamp. Writes to the main resource don't change the status and
+// status writes change nothing but the status. The generation is bumped by
+// changes of anything but the metadata and the status, and when the deletion
+// of an object with finalizers starts.
+//
+// Patches are JSON merge patches and server-side apply is emulated with one.
+// The store tracks no field managers: an apply never conflicts, doesn't remove
+// the fields the applier stopped setting and replaces lists instead of merging
+// them by key, e.g. the containers of a pod. Neither are objects validated or
+// defaulted. Tests of these paths of the apply still need envtest.
+type objectStore struct {
+	scheme  *runtime.Scheme
+	mu      sync.Mutex
+	version int
+	objects map[objectKey]client.Object
+}
+
+type objectKey struct {
+	gvk schema.GroupVersionKind
+	types.NamespacedName
+}
+
+// newObjectStore returns the store seeded with the objects, they get a uid and
+// a resourceVersion as if they were created.
+func newObjectStore(s *runtime.Scheme, objs ...client.Object) *objectStore {
+	store := &objectStore{scheme: s, objects: map[objectKey]client.Object{}}
+	for _, obj := range objs {
+		if err := store.Create(context.Background(), obj); err != nil {
+			panic(fmt.Errorf("failed to seed the object store: %w", err))
+		}
+	}
+	return store
+}
+
+func (s *objectStore) Get(_ context.Context, t types.NamespacedName, co client.Object) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, err := s.key(co, t)
+	if err != nil {
+		return err
+	}
+	stored, ok := s.objects[key]
+	if !ok {
+		return notFound(key)
+	}
+	return copyInto(co, stored)
+}
+
+func (s *objectStore) Create(_ context.Context, co client.Object) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if co.GetResourceVersion() != "" {
+		return apierrors.NewBadRequest("resourceVersion can not be set for Create requests")
+	}
+	if co.GetName() == "" && co.GetGenerateName() != "" {
+		co.SetName(co.GetGenerateName() + utilrand.String(5))
+	}
+	key, err := s.key(co, client.ObjectKeyFromObject(co))
+	if err != nil {
+		return err
+	}
+	if key.Name == "" {
+		return apierrors.NewBadRequest("name or generateName is required")
+	}
+	if _, ok := s.objects[key]; ok {
+		return apierrors.NewAlreadyExists(groupResource(key.gvk), key.Name)
+	}
+
+	if co.GetUID() == "" {
+		co.SetUID(uuid.NewUUID())
+	}
+	if created := co.GetCreationTimestamp(); created.IsZero() {
+		co.SetCreationTimestamp(metav1.Now())
+	}
+	co.SetGeneration(1)
+	s.store(key, co)
+	return nil
+}
+
+func (s *objectStore) Update(_ context.Context, co client.Object) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, stored, err := s.live(co)
+	if err != nil {
+		return err
+	}
+	updated := co.DeepCopyObject().(client.Object)
+	copyStatus(updated, stored)
+	return s.write(key, stored, updated, co)
+}
+
+func (s *objectStore) StatusUpdate(_ context.Context, co client.Object) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, stored, err := s.live(co)
+	if err != nil {
+		return err
+	}
+	if err := noStatus(co); err != nil {
+		return err
+	}
+	updated := stored.DeepCopyObject().(client.Object)
+	copyStatus(updated, co)
+	return s.write(key, stored, updated, co)
+}
+
+func (s *objectStore) Patch(_ context.Context, co client.Object, patch client.Patch, _ ...client.PatchOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if patch.Type() == types.ApplyPatchType {
+		return s.apply(co)
+	}
+	key, stored, patched, err := s.patched(co, patch)
+	if err != nil {
+		return err
+	}
+	copyStatus(patched, stored)
+	return s.write(key, stored, patched, co)
+}
+
+func (s *objectStore) StatusPatch(
+	_ context.Context,
+	co client.Object,
+	patch client.Patch,
+	_ ...client.SubResourcePatchOption,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, stored, patched, err := s.patched(co, patch)
+	if err != nil {
+		return err
+	}
+	if err := noStatus(co); err != nil {
+		return err
+	}
+	updated := stored.DeepCopyObject().(client.Object)
+	copyStatus(updated, patched)
+	updated.SetResourceVersion(patched.GetResourceVersion())
+	return s.write(key, stored, updated, co)
+}
+
+func (s *objectStore) Apply(_ context.Context, co client.Object, _ ...client.PatchOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.apply(co)
+}
+
+// apply creates a missing object and merges the applied fields into an
+// existing one. The caller holds the lock.
+func (s *objectStore) apply(co client.Object) error {
+	key, err := s.key(co, client.ObjectKeyFromObject(co))
+	if err != nil {
+		return err
+	}
+	stored, ok := s.objects[key]
+	if !ok {
+		co.SetUID(uuid.NewUUID())
+		co.SetCreationTimestamp(metav1.Now())
+		co.SetResourceVersion("")
+		co.SetGeneration(1)
+		s.store(key, co)
+		return nil
+	}
+
+	applied := co.DeepCopyObject().(client.Object)
+	applied.SetResourceVersion("")
+	data, err := json.Marshal(applied)
+	if err != nil {
+		return err
+	}
+	patched, err := mergeInto(stored, data)
+	if err != nil {
+		return err
+	}
+	copyStatus(patched, stored)
+	return s.write(key, stored, patched, co)
+}
+
+func (s *objectStore) List(_ context.Context, col client.ObjectList, opts ...client.ListOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	listOpts := (&client.ListOptions{}).ApplyOptions(opts)
+	gvk, err := apiutil.GVKForObject(col, s.scheme)
+	if err != nil {
+		return err
+	}
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+
+	var items []runtime.Object
+	for _, key := range s.matching(gvk, listOpts.Namespace, listOpts.LabelSelector) {
+		items = append(items, s.objects[key].DeepCopyObject())
+	}
+	return meta.SetList(col, items)
+}
+
+func (s *objectStore) Delete(_ context.Context, co client.Object, _ ...client.DeleteOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, err := s.key(co, client.ObjectKeyFromObject(co))
+	if err != nil {
+		return err
+	}
+	if _, ok := s.objects[key]; !ok {
+		return notFound(key)
+	}
+	s.delete(key)
+	return nil
+}
+
+func (s *objectStore) DeleteAllOf(_ context.Context, co client.Object, opts ...client.DeleteAllOfOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleteOpts := (&client.DeleteAllOfOptions{}).ApplyOptions(opts)
+	gvk, err := apiutil.GVKForObject(co, s.scheme)
+	if err != nil {
+		return err
+	}
+	for _, key := range s.matching(gvk, deleteOpts.Namespace, deleteOpts.LabelSelector) {
+		s.delete(key)
+	}
+	return nil
+}
+
+func (s *objectStore) key(obj runtime.Object, t types.NamespacedName) (objectKey, error) {
+	gvk, err := apiutil.GVKForObject(obj, s.scheme)
+	if err != nil {
+		return objectKey{}, err
+	}
+	return objectKey{gvk: gvk, NamespacedName: t}, nil
+}
+
+// live returns the stored object for a write of the object, the write fails
+// with a conflict if the object is stale. The caller holds the lock.
+func (s *objectStore) live(co client.Object) (objectKey, client.Object, error) {
+	key, err := s.key(co, client.ObjectKeyFromObject(co))
+	if err != nil {
+		return objectKey{}, nil, err
+	}
+	stored, ok := s.objects[key]
+	if !ok {
+		return objectKey{}, nil, notFound(key)
+	}
+	if rv := co.GetResourceVersion(); rv != "" && rv != stored.GetResourceVersion() {
+		return objectKey{}, nil, conflict(key)
+	}
+	return key, stored, nil
+}
+
+// patched returns the stored object and the result of the merge patch, the
+// patch fails with a conflict if it contains a stale resourceVersion. The
+// caller holds the lock.
+func (s *objectStore) patched(co client.Object, patch client.Patch) (objectKey, client.Object, client.Object, error) {
+	if patch.Type() != types.MergePatchType {
+		return objectKey{}, nil, nil, apierrors.NewBadRequest(
+			fmt.Sprintf("patch type %s is not supported by the object store", patch.Type()))
+	}
+	data, err := patch.Data(co)
+	if err != nil {
+		return objectKey{}, nil, nil, err
+	}
+	key, err := s.key(co, client.ObjectKeyFromObject(co))
+	if err != nil {
+		return objectKey{}, nil, nil, err
+	}
+	stored, ok := s.objects[key]
+	if !ok {
+		return objectKey{}, nil, nil, notFound(key)
+	}
+
+	patched, err := mergeInto(stored, data)
+	if err != nil {
+		return objectKey{}, nil, nil, err
+	}
+	if rv := patched.GetResourceVersion(); rv != stored.GetResourceVersion() {
+		return objectKey{}, nil, nil, conflict(key)
+	}
+	return key, stored, patched, nil
+}
+
+// write stores the updated object with the fields the API server owns taken
+// from the stored one and copies the result into the object of the caller. The
+// generation is bumped if anything but the metadata and the status changed. An
+// object marked for deletion is removed with its last finalizer. The caller
+// holds the lock.
+func (s *objectStore) write(key objectKey, stored, updated, co client.Object) error {
+	updated.SetUID(stored.GetUID())
+	updated.SetCreationTimestamp(stored.GetCreationTimestamp())
+	updated.SetDeletionTimestamp(stored.GetDeletionTimestamp())
+	updated.SetGeneration(stored.GetGeneration())
+	if specChanged(stored, updated) {
+		updated.SetGeneration(stored.GetGeneration() + 1)
+	}
+	s.store(key, updated)
+	if updated.GetDeletionTimestamp() != nil && len(updated.GetFinalizers()) == 0 {
+		delete(s.objects, key)
+	}
+	return copyInto(co, updated)
+}
+
+// store keeps a copy of the object with a new resourceVersion, the object gets
+// the resourceVersion as well. The caller holds the lock.
+func (s *objectStore) store(key objectKey, co client.Object) {
+	s.version++
+	co.SetResourceVersion(strconv.Itoa(s.version))
+	stored := co.DeepCopyObject().(client.Object)
+	stored.GetObjectKind().SetGroupVersionKind(schema.GroupVersionKind{})
+	s.objects[key] = stored
+}
+
+// delete removes the object or, if it has finalizers, sets its
+// deletionTimestamp. The caller holds the lock.
+func (s *objectStore) delete(key objectKey) {
+	stored := s.objects[key]
+	if len(stored.GetFinalizers()) == 0 {
+		delete(s.objects, key)
+		return
+	}
+	if stored.GetDeletionTimestamp() == nil {
+		now := metav1.Now()
+		stored.SetDeletionTimestamp(&now)
+		stored.SetGeneration(stored.GetGeneration() + 1)
+		s.store(key, stored)
+	}
+}
+
+// specChanged is true if the objects differ in more than their metadata and
+// status. Objects which can't be converted count as changed.
+func specChanged(stored, updated client.Object) bool {
+	before, err := runtime.DefaultUnstructuredConverter.ToUnstructured(stored)
+	if err != nil {
+		return true
+	}
+	after, err := runtime.DefaultUnstructuredConverter.ToUnstructured(updated)
+	if err != nil {
+		return true
+	}
+	for _, field := range []string{"apiVersion", "kind", "metadata", "status"} {
+		delete(before, field)
+		delete(after, field)
+	}
+	return !reflect.DeepEqual(before, after)
+}
+
+// matching returns the sorted keys of the objects of the kind in the namespace
+// matching the label selector, an empty namespace matches all namespaces. The
+// caller holds the lock.
+func (s *objectStore) matching(gvk schema.GroupVersionKind, namespace string, selector labels.Selector) []objectKey {
+	var keys []objectKey
+	for key, obj := range s.objects {
+		if key.gvk != gvk || (namespace != "" && key.Namespace != namespace) {
+			continue
+		}
+		if selector != nil && !selector.Matches(labels.Set(obj.GetLabels())) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	return keys
+}
+
+// mergeInto returns a copy of the object with the JSON merge patch applied.
+func mergeInto(obj client.Object, patch []byte) (client.Object, error) {
+	original, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var target, changes interface{}
+	if err := json.Unmarshal(original, &target); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &changes); err != nil {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid merge patch: %v", err))
+	}
+	merged, err := json.Marshal(mergePatch(target, changes))
+	if err != nil {
+		return nil, err
+	}
+
+	patched := reflect.New(reflect.TypeOf(obj).Elem()).Interface().(client.Object)
+	if err := json.Unmarshal(merged, patched); err != nil {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid merge patch: %v", err))
+	}
+	return patched, nil
+}
+
+// mergePatch applies the JSON merge patch (RFC 7386) to the target.
+func mergePatch(target, patch interface{}) interface{} {
+	changes, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	merged, ok := target.(map[string]interface{})
+	if !ok {
+		merged = map[string]interface{}{}
+	}
+	for field, value := range changes {
+		if value == nil {
+			delete(merged, field)
+			continue
+		}
+		merged[field] = mergePatch(merged[field], value)
+	}
+	return merged
+}
+
+// copyStatus copies a deep copy of the status of src into dst, both are of
+// the same type. Objects without a status are left as they are.
+func copyStatus(dst, src client.Object) {
+	dstStatus := reflect.ValueOf(dst).Elem().FieldByName("Status")
+	if !dstStatus.IsValid() {
+		return
+	}
+	dstStatus.Set(reflect.ValueOf(src.DeepCopyObject()).Elem().FieldByName("Status"))
+}
+
+// noStatus returns an error for status writes of objects without a status.
+func noStatus(co client.Object) error {
+	if reflect.ValueOf(co).Elem().FieldByName("Status").IsValid() {
+		return nil
+	}
+	return apierrors.NewNotFound(schema.GroupResource{Resource: "status"}, co.GetName())
+}
+
+func notFound(key objectKey) error {
+	return apierrors.NewNotFound(groupResource(key.gvk), key.Name)
+}
+
+func conflict(key objectKey) error {
+	return apierrors.NewConflict(groupResource(key.gvk), key.Name,
+		fmt.Errorf("the object has been modified; please apply your changes to the latest version and try again"))
+}
+
+func groupResource(gvk schema.GroupVersionKind) schema.GroupResource {
+	resource, _ := meta.UnsafeGuessKindToResource(gvk)
+	return resource.GroupResource()
+}
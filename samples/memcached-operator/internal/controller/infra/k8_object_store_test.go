@@ -0,0 +1,306 @@
+package infra_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"example.com/m/v2/internal/controller/infra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func Test_ObjectStore_createAndGet(t *testing.T) {
+	ctx := context.Background()
+	_, seeded := tnnAndPod("seeded-pod", "default")
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{Objects: []client.Object{seeded}})
+	tnn, pod := tnnAndPod("stored-pod", "default")
+	pod.OwnerReferences = []v1.OwnerReference{{
+		APIVersion: "cache.example.com/v1alpha1",
+		Kind:       "Memcached",
+		Name:       "owner",
+		UID:        "owner-uid",
+		Controller: ptr.To(true),
+	}}
+
+	if err := k8.Get(ctx, tnn, &corev1.Pod{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+	if err := k8.Create(ctx, pod); err != nil {
+		t.Fatalf("unexpected error creating pod %v", err)
+	}
+	if pod.ResourceVersion == "" {
+		t.Error("expected resourceVersion to be set")
+	}
+	if err := k8.Create(ctx, pod.DeepCopy()); err == nil {
+		t.Error("expected error creating pod with resourceVersion, got nothing")
+	}
+	duplicate := pod.DeepCopy()
+	duplicate.ResourceVersion = ""
+	if err := k8.Create(ctx, duplicate); !apierrors.IsAlreadyExists(err) {
+		t.Errorf("expected AlreadyExists, got %v", err)
+	}
+
+	got := &corev1.Pod{}
+	if err := k8.Get(ctx, tnn, got); err != nil {
+		t.Fatalf("unexpected error getting pod %v", err)
+	}
+	if len(got.OwnerReferences) != 1 || got.OwnerReferences[0].UID != "owner-uid" {
+		t.Errorf("expected owner reference to be kept, got %v", got.OwnerReferences)
+	}
+}
+
+func Test_ObjectStore_unseededStubStoresObjects(t *testing.T) {
+	ctx := context.Background()
+	tnn, pod := tnnAndPod("unseeded-pod", "default")
+	k8 := infra.NewK8CliStub(nil, nil)
+
+	if err := k8.Create(ctx, pod); err != nil {
+		t.Fatalf("unexpected error creating pod %v", err)
+	}
+	got := &corev1.Pod{}
+	if err := k8.Get(ctx, tnn, got); err != nil {
+		t.Fatalf("unexpected error getting pod %v", err)
+	}
+	if got.UID != pod.UID || got.Spec.Containers[0].Image != "busybox" {
+		t.Errorf("expected the created pod, got %v", got)
+	}
+}
+
+func Test_ObjectStore_bumpsGeneration(t *testing.T) {
+	ctx := context.Background()
+	tnn, pod := tnnAndPod("generation-pod", "default")
+	pod.Finalizers = []string{"cache.example.com/finalizer"}
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{Objects: []client.Object{pod}})
+	if pod.Generation != 1 {
+		t.Fatalf("expected generation 1 after create, got %d", pod.Generation)
+	}
+
+	pod.Labels = map[string]string{"metadata": "only"}
+	pod.Generation = 7
+	if err := k8.Update(ctx, pod); err != nil {
+		t.Fatalf("unexpected error updating pod %v", err)
+	}
+	if pod.Generation != 1 {
+		t.Errorf("expected generation 1 after a metadata change, got %d", pod.Generation)
+	}
+
+	pod.Status.Phase = corev1.PodRunning
+	if err := k8.StatusUpdate(ctx, pod); err != nil {
+		t.Fatalf("unexpected error updating pod status %v", err)
+	}
+	if pod.Generation != 1 {
+		t.Errorf("expected generation 1 after a status change, got %d", pod.Generation)
+	}
+
+	pod.Spec.Containers[0].Image = "ubuntu"
+	if err := k8.Update(ctx, pod); err != nil {
+		t.Fatalf("unexpected error updating pod %v", err)
+	}
+	if pod.Generation != 2 {
+		t.Errorf("expected generation 2 after a spec change, got %d", pod.Generation)
+	}
+
+	if err := k8.Delete(ctx, pod); err != nil {
+		t.Fatalf("unexpected error deleting pod %v", err)
+	}
+	got := &corev1.Pod{}
+	if err := k8.Get(ctx, tnn, got); err != nil || got.Generation != 3 {
+		t.Errorf("expected generation 3 after the deletion started, got %d and %v", got.Generation, err)
+	}
+}
+
+func Test_ObjectStore_conflicts(t *testing.T) {
+	ctx := context.Background()
+	_, pod := tnnAndPod("conflicting-pod", "default")
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{Objects: []client.Object{pod}})
+
+	stale := pod.DeepCopy()
+	pod.Spec.Containers[0].Image = "ubuntu"
+	if err := k8.Update(ctx, pod); err != nil {
+		t.Fatalf("unexpected error updating pod %v", err)
+	}
+
+	stale.Spec.Containers[0].Image = "fedora"
+	if err := k8.Update(ctx, stale); !apierrors.IsConflict(err) {
+		t.Errorf("expected Conflict, got %v", err)
+	}
+	if err := k8.Patch(ctx, stale, client.MergeFromWithOptions(stale.DeepCopy(), client.MergeFromWithOptimisticLock{})); !apierrors.IsConflict(err) {
+		t.Errorf("expected Conflict, got %v", err)
+	}
+}
+
+func Test_ObjectStore_statusSubresource(t *testing.T) {
+	ctx := context.Background()
+	tnn, pod := tnnAndPod("status-pod", "default")
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{Objects: []client.Object{pod}})
+
+	pod.Status.Phase = corev1.PodRunning
+	if err := k8.Update(ctx, pod); err != nil {
+		t.Fatalf("unexpected error updating pod %v", err)
+	}
+	got := &corev1.Pod{}
+	_ = k8.Get(ctx, tnn, got)
+	if got.Status.Phase != "" {
+		t.Errorf("expected update to keep the status, got phase %s", got.Status.Phase)
+	}
+
+	got.Status.Phase = corev1.PodRunning
+	if err := k8.StatusUpdate(ctx, got); err != nil {
+		t.Fatalf("unexpected error updating pod status %v", err)
+	}
+	_ = k8.Get(ctx, tnn, got)
+	if got.Status.Phase != corev1.PodRunning {
+		t.Errorf("expected phase Running, got %s", got.Status.Phase)
+	}
+}
+
+func Test_ObjectStore_deleteWithFinalizer(t *testing.T) {
+	ctx := context.Background()
+	tnn, pod := tnnAndPod("finalized-pod", "default")
+	pod.Finalizers = []string{"cache.example.com/finalizer"}
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{Objects: []client.Object{pod}})
+
+	if err := k8.Delete(ctx, pod); err != nil {
+		t.Fatalf("unexpected error deleting pod %v", err)
+	}
+	got := &corev1.Pod{}
+	if err := k8.Get(ctx, tnn, got); err != nil {
+		t.Fatalf("expected pod to be kept by its finalizer, got %v", err)
+	}
+	if got.DeletionTimestamp.IsZero() {
+		t.Error("expected deletionTimestamp to be set")
+	}
+
+	patch := client.MergeFrom(got.DeepCopy())
+	got.Finalizers = nil
+	if err := k8.Patch(ctx, got, patch); err != nil {
+		t.Fatalf("unexpected error removing finalizer %v", err)
+	}
+	if err := k8.Get(ctx, tnn, &corev1.Pod{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected NotFound after removing the finalizer, got %v", err)
+	}
+}
+
+func Test_ObjectStore_apply(t *testing.T) {
+	ctx := context.Background()
+	_, seeded := tnnAndPod("seeded-pod", "default")
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{Objects: []client.Object{seeded}})
+	tnn, pod := tnnAndPod("applied-pod", "default")
+	pod.APIVersion, pod.Kind = "v1", "Pod"
+	opts := []client.PatchOption{client.FieldOwner("infra-test"), client.ForceOwnership}
+
+	if err := k8.Apply(ctx, pod.DeepCopy(), opts...); err != nil {
+		t.Fatalf("unexpected error applying new pod %v", err)
+	}
+
+	pod.Labels = map[string]string{"applied": "twice"}
+	if err := k8.Apply(ctx, pod.DeepCopy(), opts...); err != nil {
+		t.Fatalf("unexpected error applying pod %v", err)
+	}
+
+	got := &corev1.Pod{}
+	if err := k8.Get(ctx, tnn, got); err != nil {
+		t.Fatalf("unexpected error getting pod %v", err)
+	}
+	if got.Labels["applied"] != "twice" {
+		t.Errorf("expected label applied=twice, got %v", got.Labels)
+	}
+}
+
+func Test_ObjectStore_listAndDeleteAllOf(t *testing.T) {
+	ctx := context.Background()
+	_, first := tnnAndPod("first-stored-pod", "default")
+	_, second := tnnAndPod("second-stored-pod", "default")
+	_, other := tnnAndPod("other-stored-pod", "other")
+	for _, pod := range []*corev1.Pod{first, second, other} {
+		pod.Labels = map[string]string{"app": "memcached"}
+	}
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{Objects: []client.Object{first, second, other}})
+	selector := []client.ListOption{client.InNamespace("default"), client.MatchingLabels{"app": "memcached"}}
+
+	pods := &corev1.PodList{}
+	if err := k8.List(ctx, pods, selector...); err != nil {
+		t.Fatalf("unexpected error listing pods %v", err)
+	}
+	if len(pods.Items) != 2 {
+		t.Errorf("expected 2 pods, got %d", len(pods.Items))
+	}
+
+	if err := k8.DeleteAllOf(ctx, &corev1.Pod{}, client.InNamespace("default")); err != nil {
+		t.Fatalf("unexpected error deleting pods %v", err)
+	}
+	if err := k8.List(ctx, pods, client.MatchingLabels{"app": "memcached"}); err != nil {
+		t.Fatalf("unexpected error listing pods %v", err)
+	}
+	if len(pods.Items) != 1 || pods.Items[0].Namespace != "other" {
+		t.Errorf("expected only the pod in the other namespace, got %v", pods.Items)
+	}
+}
+
+func Test_ObjectStore_answersAfterConfiguredErrors(t *testing.T) {
+	ctx := context.Background()
+	tnn, pod := tnnAndPod("seeded-pod", "default")
+	createErr := errors.New("Create error")
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{
+		Errors:  infra.StubErrors{"Create": {createErr}},
+		Objects: []client.Object{pod},
+	})
+
+	if err := k8.Create(ctx, pod.DeepCopy()); !errors.Is(err, createErr) {
+		t.Errorf("expected %v, got %v", createErr, err)
+	}
+	duplicate := pod.DeepCopy()
+	duplicate.ResourceVersion = ""
+	if err := k8.Create(ctx, duplicate); !apierrors.IsAlreadyExists(err) {
+		t.Errorf("expected AlreadyExists for the seeded pod, got %v", err)
+	}
+
+	got := &corev1.Pod{}
+	if err := k8.Get(ctx, tnn, got); err != nil {
+		t.Fatalf("unexpected error getting pod %v", err)
+	}
+	if got.UID == "" || got.ResourceVersion != pod.ResourceVersion {
+		t.Errorf("expected the seeded pod with uid and resourceVersion %s, got %v", pod.ResourceVersion, got.ObjectMeta)
+	}
+	if err := k8.Get(ctx, types.NamespacedName{Name: "missing-pod", Namespace: "default"}, &corev1.Pod{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+}
+
+func Test_ObjectStore_mergePatches(t *testing.T) {
+	ctx := context.Background()
+	tnn, pod := tnnAndPod("patched-pod", "default")
+	pod.Labels = map[string]string{"app": "memcached", "tier": "cache"}
+	k8 := infra.NewK8CliStubWithConfig(infra.StubConfig{Objects: []client.Object{pod}})
+
+	patch := client.MergeFrom(pod.DeepCopy())
+	delete(pod.Labels, "tier")
+	pod.Status.Phase = corev1.PodRunning
+	if err := k8.Patch(ctx, pod, patch); err != nil {
+		t.Fatalf("unexpected error patching pod %v", err)
+	}
+	got := &corev1.Pod{}
+	_ = k8.Get(ctx, tnn, got)
+	if len(got.Labels) != 1 || got.Labels["app"] != "memcached" {
+		t.Errorf("expected only the label app=memcached, got %v", got.Labels)
+	}
+	if got.Status.Phase != "" {
+		t.Errorf("expected patch to keep the status, got phase %s", got.Status.Phase)
+	}
+
+	patch = client.MergeFrom(got.DeepCopy())
+	got.Status.Phase = corev1.PodRunning
+	got.Labels = nil
+	if err := k8.StatusPatch(ctx, got, patch); err != nil {
+		t.Fatalf("unexpected error patching pod status %v", err)
+	}
+	_ = k8.Get(ctx, tnn, got)
+	if got.Status.Phase != corev1.PodRunning || got.Labels["app"] != "memcached" {
+		t.Errorf("expected only the phase to change, got phase %s and labels %v", got.Status.Phase, got.Labels)
+	}
+}
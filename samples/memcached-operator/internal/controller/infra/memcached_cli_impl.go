@@ -0,0 +1,297 @@
+package infra
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemcachedCliImpl is a Thin Wrapper (James Shore) encapsulating the
+// Infrastructure Wrapper and Embedded Stub for the memcached text protocol. Each
+// command opens a connection to the address, e.g. '10.0.0.1:11211', sends the
+// command and reads the reply. Besides sending it supports Output Tracking so
+// that tests can assert on the commands sent. It's safe for concurrent use.
+type MemcachedCliImpl struct {
+	cli      memcachedCli
+	mu       sync.Mutex
+	trackers []*CommandTracker
+}
+
+// Package scoped interface which is used by the Thin Wrapper and implemented by
+// Infrastructure Wrapper and the Embedded Stub.
+type memcachedCli interface {
+	Send(ctx context.Context, addr, command string) ([]string, error)
+}
+
+// NewMemcachedCliImpl returns the wrapper for memcached. A command which isn't
+// answered within the timeout fails, including the time to connect.
+func NewMemcachedCliImpl(timeout time.Duration) *MemcachedCliImpl {
+	return &MemcachedCliImpl{cli: &memcachedCliActual{timeout: timeout}}
+}
+
+// NewMemcachedCliStub returns the Embedded Stub answering with the configured
+// responses, and like a healthy memcached without them.
+func NewMemcachedCliStub(responses MemcachedStubResponses) *MemcachedCliImpl {
+	return &MemcachedCliImpl{cli: &memcachedCliStub{responses: responses}}
+}
+
+// Command is a command as seen by the Output Tracking, failed commands
+// included.
+type Command struct {
+	Addr    string
+	Command string
+}
+
+// TrackCommands starts Output Tracking. The tracker records every command sent
+// after it was created.
+func (m *MemcachedCliImpl) TrackCommands() *CommandTracker {
+	tracker := &CommandTracker{}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.trackers = append(m.trackers, tracker)
+	return tracker
+}
+
+// CommandTracker records the commands sent with the MemcachedCliImpl.
+type CommandTracker struct {
+	mu       sync.Mutex
+	commands []Command
+}
+
+func (t *CommandTracker) add(command Command) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.commands = append(t.commands, command)
+}
+
+// Data returns the recorded commands in the order they were sent.
+func (t *CommandTracker) Data() []Command {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Command{}, t.commands...)
+}
+
+func (m *MemcachedCliImpl) send(ctx context.Context, addr, command string) ([]string, error) {
+	m.mu.Lock()
+	trackers := m.trackers
+	m.mu.Unlock()
+	for _, tracker := range trackers {
+		tracker.add(Command{Addr: addr, Command: command})
+	}
+
+	return m.cli.Send(ctx, addr, command)
+}
+
+// Version returns the version of memcached, e.g. '1.6.26'.
+func (m *MemcachedCliImpl) Version(ctx context.Context, addr string) (string, error) {
+	lines, err := m.send(ctx, addr, "version")
+	if err != nil {
+		return "", err
+	}
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], "VERSION ") {
+		return "", unexpectedReply(lines)
+	}
+	return strings.TrimPrefix(lines[0], "VERSION "), nil
+}
+
+// Stats returns the general-purpose statistics by name, e.g. 'curr_items'.
+func (m *MemcachedCliImpl) Stats(ctx context.Context, addr string) (map[string]string, error) {
+	return m.stats(ctx, addr, "stats")
+}
+
+// StatsSlabs returns the statistics of the slabs by name. The statistics of a
+// slab class are prefixed with the class, e.g. '1:chunk_size'.
+func (m *MemcachedCliImpl) StatsSlabs(ctx context.Context, addr string) (map[string]string, error) {
+	return m.stats(ctx, addr, "stats slabs")
+}
+
+func (m *MemcachedCliImpl) stats(ctx context.Context, addr, command string) (map[string]string, error) {
+	lines, err := m.send(ctx, addr, command)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 || lines[len(lines)-1] != "END" {
+		return nil, unexpectedReply(lines)
+	}
+
+	stats := map[string]string{}
+	for _, line := range lines[:len(lines)-1] {
+		// STAT <name> <value>
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) == 3 && fields[0] == "STAT" {
+			stats[fields[1]] = fields[2]
+		}
+	}
+	return stats, nil
+}
+
+// FlushAll invalidates all items.
+func (m *MemcachedCliImpl) FlushAll(ctx context.Context, addr string) error {
+	return m.expectOK(ctx, addr, "flush_all")
+}
+
+// Verbosity sets the logging level of memcached.
+func (m *MemcachedCliImpl) Verbosity(ctx context.Context, addr string, level int) error {
+	return m.expectOK(ctx, addr, fmt.Sprintf("verbosity %d", level))
+}
+
+// Shutdown stops memcached with 'shutdown graceful' after the running
+// requests are served. memcached only accepts it when started with '-A'.
+func (m *MemcachedCliImpl) Shutdown(ctx context.Context, addr string) error {
+	// the reply is empty, memcached closes the connection when it stops
+	lines, err := m.send(ctx, addr, "shutdown graceful")
+	if err == nil && len(lines) != 0 {
+		err = unexpectedReply(lines)
+	}
+	return err
+}
+
+func (m *MemcachedCliImpl) expectOK(ctx context.Context, addr, command string) error {
+	lines, err := m.send(ctx, addr, command)
+	if err == nil && (len(lines) == 0 || lines[len(lines)-1] != "OK") {
+		err = unexpectedReply(lines)
+	}
+	return err
+}
+
+func unexpectedReply(lines []string) error {
+	return fmt.Errorf("unexpected reply %q", strings.Join(lines, " "))
+}
+
+// Infrastructure Wrapper which is the real implementation using a TCP connection
+type memcachedCliActual struct {
+	timeout time.Duration
+}
+
+// Send opens a connection, sends a single command and reads the reply until its
+// terminating line. A connection closed by memcached ends the reply as well,
+// e.g. after 'shutdown graceful'.
+func (m *memcachedCliActual) Send(ctx context.Context, addr, command string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	if _, err := fmt.Fprintf(conn, "%s\r\n", command); err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if errors.Is(err, io.EOF) {
+			return lines, nil
+		}
+		if err != nil {
+			return lines, err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		lines = append(lines, line)
+		if isLastReplyLine(line) {
+			return lines, replyError(line)
+		}
+	}
+}
+
+func isLastReplyLine(line string) bool {
+	switch {
+	case line == "END", line == "OK", strings.HasPrefix(line, "VERSION "):
+		return true
+	default:
+		return replyError(line) != nil
+	}
+}
+
+// replyError returns the error of an error reply, e.g. of 'ERROR: shutdown not
+// enabled' if memcached wasn't started with '-A'.
+func replyError(line string) error {
+	if strings.HasPrefix(line, "ERROR") || strings.HasPrefix(line, "CLIENT_ERROR") || strings.HasPrefix(line, "SERVER_ERROR") {
+		return fmt.Errorf("memcached replied: %s", line)
+	}
+	return nil
+}
+
+// MemcachedStubKey selects the commands sent to an address, e.g.
+// {Addr: "10.0.0.1:11211", Command: "flush_all"}. An empty Addr selects the
+// command for all addresses.
+type MemcachedStubKey struct {
+	Addr    string
+	Command string
+}
+
+// MemcachedStubResponse is a Configurable Response. The stub returns Err if
+// it's set, otherwise the reply Lines without the line endings.
+type MemcachedStubResponse struct {
+	Lines []string
+	Err   error
+}
+
+// Configurable Responses. Key: address and command, value: ordered responses.
+type MemcachedStubResponses = map[MemcachedStubKey][]MemcachedStubResponse
+
+// stubReplies are the replies of a healthy memcached by the first word of the
+// command.
+var stubReplies = map[string][]string{
+	"version":   {"VERSION 1.6.26"},
+	"stats":     {"END"},
+	"flush_all": {"OK"},
+	"verbosity": {"OK"},
+	"shutdown":  nil,
+}
+
+// Embedded Stub with Configurable Responses. Each returned response is removed,
+// responses for the address are returned before responses for all addresses.
+// Without a response the stub replies like a healthy memcached, unknown
+// commands with 'ERROR'. It's safe for concurrent use.
+type memcachedCliStub struct {
+	mu        sync.Mutex
+	responses MemcachedStubResponses
+}
+
+func (m *memcachedCliStub) Send(_ context.Context, addr, command string) ([]string, error) {
+	response := m.next(addr, command)
+	if response.Err != nil {
+		return nil, response.Err
+	}
+	if len(response.Lines) > 0 {
+		return response.Lines, replyError(response.Lines[len(response.Lines)-1])
+	}
+	return response.Lines, nil
+}
+
+func (m *memcachedCliStub) next(addr, command string) MemcachedStubResponse {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range []MemcachedStubKey{{Addr: addr, Command: command}, {Command: command}} {
+		if responses := m.responses[key]; len(responses) > 0 {
+			m.responses[key] = responses[1:]
+			return responses[0]
+		}
+	}
+
+	verb, _, _ := strings.Cut(command, " ")
+	lines, ok := stubReplies[verb]
+	if !ok {
+		lines = []string{"ERROR"}
+	}
+	return MemcachedStubResponse{Lines: append([]string{}, lines...)}
+}
@@ -0,0 +1,238 @@
+package infra_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/m/v2/internal/controller/infra"
+)
+
+func Test_Memcached_commandsAgainstFakeServer(t *testing.T) {
+	ctx := context.Background()
+	server := startFakeMemcachedServer(t)
+	server.Set("greeting", "hello")
+	mc := infra.NewMemcachedCliImpl(time.Second)
+
+	version, err := mc.Version(ctx, server.Addr())
+	if err != nil || version != "1.6.26-fake" {
+		t.Errorf("expected version 1.6.26-fake, got %q and %v", version, err)
+	}
+
+	stats, err := mc.Stats(ctx, server.Addr())
+	if err != nil || stats["curr_items"] != "1" {
+		t.Errorf("expected 1 item, got %v and %v", stats, err)
+	}
+	slabs, err := mc.StatsSlabs(ctx, server.Addr())
+	if err != nil || slabs["1:used_chunks"] != "1" || slabs["active_slabs"] != "1" {
+		t.Errorf("expected 1 used chunk in 1 slab, got %v and %v", slabs, err)
+	}
+
+	if err := mc.Verbosity(ctx, server.Addr(), 2); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if got := server.Verbosity(); got != 2 {
+		t.Errorf("expected verbosity 2, got %d", got)
+	}
+
+	if err := mc.FlushAll(ctx, server.Addr()); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	stats, err = mc.Stats(ctx, server.Addr())
+	if err != nil || stats["curr_items"] != "0" || stats["cmd_flush"] != "1" {
+		t.Errorf("expected no items after 1 flush, got %v and %v", stats, err)
+	}
+}
+
+func Test_Memcached_fakeServerStoresItems(t *testing.T) {
+	server := startFakeMemcachedServer(t)
+
+	reply := rawCommand(t, server.Addr(), "set greeting 0 0 5\r\nhello\r\nget greeting missing\r\n", 4)
+	expected := []string{"STORED", "VALUE greeting 0 5", "hello", "END"}
+	if !reflect.DeepEqual(reply, expected) {
+		t.Errorf("expected %q, got %q", expected, reply)
+	}
+}
+
+func Test_Memcached_shutdownStopsFakeServer(t *testing.T) {
+	ctx := context.Background()
+	server := startFakeMemcachedServer(t)
+	mc := infra.NewMemcachedCliImpl(time.Second)
+
+	if err := mc.Shutdown(ctx, server.Addr()); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := mc.Version(ctx, server.Addr()); err == nil {
+		t.Error("expected the server to be stopped, got a version")
+	}
+}
+
+func Test_Memcached_replyErrors(t *testing.T) {
+	// replies like memcached started without '-A' to 'shutdown'
+	addr := serveOnce(t, func(conn net.Conn) {
+		_, _ = bufio.NewReader(conn).ReadString('\n')
+		_, _ = fmt.Fprint(conn, "ERROR: shutdown not enabled\r\n")
+	})
+	mc := infra.NewMemcachedCliImpl(time.Second)
+
+	err := mc.Shutdown(context.Background(), addr)
+	if err == nil || !strings.Contains(err.Error(), "memcached replied: ERROR") {
+		t.Errorf("expected the ERROR reply, got %v", err)
+	}
+}
+
+func Test_Memcached_timeout(t *testing.T) {
+	// accepts connections, but never replies
+	addr := serveOnce(t, func(conn net.Conn) {
+		time.Sleep(time.Second)
+	})
+	timeout := 50 * time.Millisecond
+	mc := infra.NewMemcachedCliImpl(timeout)
+
+	start := time.Now()
+	_, err := mc.Version(context.Background(), addr)
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*timeout {
+		t.Errorf("expected to give up after %v, took %v", timeout, elapsed)
+	}
+}
+
+func Test_Memcached_stubRepliesLikeHealthyMemcached(t *testing.T) {
+	ctx := context.Background()
+	mc := infra.NewMemcachedCliStub(nil)
+
+	if version, err := mc.Version(ctx, "10.0.0.1:11211"); err != nil || version == "" {
+		t.Errorf("expected a version, got %q and %v", version, err)
+	}
+	if stats, err := mc.Stats(ctx, "10.0.0.1:11211"); err != nil || len(stats) != 0 {
+		t.Errorf("expected no stats, got %v and %v", stats, err)
+	}
+	for _, err := range []error{
+		mc.FlushAll(ctx, "10.0.0.1:11211"),
+		mc.Verbosity(ctx, "10.0.0.1:11211", 1),
+		mc.Shutdown(ctx, "10.0.0.1:11211"),
+	} {
+		if err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+}
+
+func Test_Memcached_stubWithConfigurableResponses(t *testing.T) {
+	ctx := context.Background()
+	refused := errors.New("dial tcp 10.0.0.2:11211: connection refused")
+	mc := infra.NewMemcachedCliStub(infra.MemcachedStubResponses{
+		{Command: "stats"}: {
+			{Lines: []string{"STAT curr_items 42", "END"}},
+		},
+		{Addr: "10.0.0.2:11211", Command: "flush_all"}: {
+			{Err: refused},
+			{Lines: []string{"SERVER_ERROR out of memory"}},
+		},
+	})
+
+	stats, err := mc.Stats(ctx, "10.0.0.1:11211")
+	if err != nil || stats["curr_items"] != "42" {
+		t.Errorf("expected 42 items, got %v and %v", stats, err)
+	}
+
+	if err := mc.FlushAll(ctx, "10.0.0.1:11211"); err != nil {
+		t.Errorf("expected flush of other address to pass, got %v", err)
+	}
+	if err := mc.FlushAll(ctx, "10.0.0.2:11211"); err != refused {
+		t.Errorf("expected %v, got %v", refused, err)
+	}
+	if err := mc.FlushAll(ctx, "10.0.0.2:11211"); err == nil || !strings.Contains(err.Error(), "SERVER_ERROR") {
+		t.Errorf("expected SERVER_ERROR, got %v", err)
+	}
+	if err := mc.FlushAll(ctx, "10.0.0.2:11211"); err != nil {
+		t.Errorf("expected flush to pass after the responses, got %v", err)
+	}
+}
+
+func Test_Memcached_tracksCommands(t *testing.T) {
+	ctx := context.Background()
+	mc := infra.NewMemcachedCliStub(infra.MemcachedStubResponses{
+		{Command: "flush_all"}: {{Err: errors.New("connection refused")}},
+	})
+
+	_ = mc.FlushAll(ctx, "10.0.0.1:11211")
+	tracker := mc.TrackCommands()
+	_ = mc.FlushAll(ctx, "10.0.0.1:11211")
+	_ = mc.Verbosity(ctx, "10.0.0.2:11211", 1)
+
+	expected := []infra.Command{
+		{Addr: "10.0.0.1:11211", Command: "flush_all"},
+		{Addr: "10.0.0.2:11211", Command: "verbosity 1"},
+	}
+	if got := tracker.Data(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func startFakeMemcachedServer(t *testing.T) *fakeMemcachedServer {
+	t.Helper()
+	server, err := newFakeMemcachedServer()
+	if err != nil {
+		t.Fatalf("failed to start fake memcached: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := server.Close(); err != nil {
+			t.Errorf("failed to stop fake memcached: %v", err)
+		}
+	})
+	return server
+}
+
+// serveOnce answers the first connection to the returned address with handle.
+func serveOnce(t *testing.T, handle func(net.Conn)) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		handle(conn)
+	}()
+	return listener.Addr().String()
+}
+
+// rawCommand sends the raw request and reads the given number of reply lines.
+func rawCommand(t *testing.T, addr, request string, lines int) []string {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(time.Second))
+
+	if _, err := fmt.Fprint(conn, request); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var reply []string
+	reader := bufio.NewReader(conn)
+	for range lines {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("unexpected error %v after %q", err, reply)
+		}
+		reply = append(reply, strings.TrimRight(line, "\r\n"))
+	}
+	return reply
+}
@@ -0,0 +1,251 @@
+package infra_test
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fakeMemcachedServer is a tiny in-process memcached for the tests of the
+// MemcachedCliImpl. It listens on a loopback TCP port and speaks enough of
+// the text protocol for the commands of the operator: get, set, version, stats,
+// stats slabs, flush_all, verbosity and shutdown graceful. Items never expire
+// and there is a single slab class.
+type fakeMemcachedServer struct {
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu        sync.Mutex
+	closed    bool
+	conns     map[net.Conn]struct{}
+	items     map[string]string
+	verbosity int
+	stats     map[string]int
+}
+
+// newFakeMemcachedServer starts the server on a free loopback port.
+func newFakeMemcachedServer() (*fakeMemcachedServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &fakeMemcachedServer{
+		listener: listener,
+		conns:    map[net.Conn]struct{}{},
+		items:    map[string]string{},
+		stats:    map[string]int{},
+	}
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+// Addr returns the address the server listens on, e.g. '127.0.0.1:40123'.
+func (s *fakeMemcachedServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server and closes the open connections.
+func (s *fakeMemcachedServer) Close() error {
+	err := s.listener.Close()
+	s.mu.Lock()
+	s.closed = true
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// Set stores the item like the 'set' command.
+func (s *fakeMemcachedServer) Set(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[key] = value
+	s.stats["cmd_set"]++
+}
+
+// Verbosity returns the level set with the 'verbosity' command.
+func (s *fakeMemcachedServer) Verbosity() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.verbosity
+}
+
+func (s *fakeMemcachedServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeMemcachedServer) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			_, _ = fmt.Fprint(conn, "ERROR\r\n")
+			continue
+		}
+		if fields[0] == "quit" {
+			return
+		}
+		if fields[0] == "shutdown" {
+			// like memcached with '-A' the server stops, the connection is closed
+			go func() { _ = s.Close() }()
+			return
+		}
+
+		var data []byte
+		if fields[0] == "set" {
+			if data, err = readDataBlock(fields, reader); err != nil {
+				_, _ = fmt.Fprint(conn, "CLIENT_ERROR bad data chunk\r\n")
+				return
+			}
+		}
+		if _, err := fmt.Fprint(conn, s.reply(fields, data)); err != nil {
+			return
+		}
+	}
+}
+
+// readDataBlock reads the data block of 'set <key> <flags> <exptime> <bytes>'.
+func readDataBlock(fields []string, reader *bufio.Reader) ([]byte, error) {
+	if len(fields) < 5 {
+		return nil, nil
+	}
+	size, err := strconv.Atoi(fields[4])
+	if err != nil || size < 0 {
+		return nil, fmt.Errorf("bad data size %q", fields[4])
+	}
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, err
+	}
+	if string(data[size:]) != "\r\n" {
+		return nil, errors.New("data block not terminated")
+	}
+	return data[:size], nil
+}
+
+// reply answers the command, data is the data block of 'set'.
+func (s *fakeMemcachedServer) reply(fields []string, data []byte) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case fields[0] == "version":
+		return "VERSION 1.6.26-fake\r\n"
+	case fields[0] == "verbosity" && len(fields) >= 2:
+		level, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return "CLIENT_ERROR bad command line format\r\n"
+		}
+		s.verbosity = level
+		return "OK\r\n"
+	case fields[0] == "flush_all":
+		s.items = map[string]string{}
+		s.stats["cmd_flush"]++
+		return "OK\r\n"
+	case fields[0] == "stats" && len(fields) == 1:
+		return s.generalStats()
+	case fields[0] == "stats" && fields[1] == "slabs":
+		return s.slabStats()
+	case fields[0] == "get" && len(fields) >= 2:
+		return s.get(fields[1:])
+	case fields[0] == "set" && len(fields) >= 5:
+		return s.set(fields, data)
+	default:
+		return "ERROR\r\n"
+	}
+}
+
+func (s *fakeMemcachedServer) generalStats() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "STAT pid %d\r\n", os.Getpid())
+	fmt.Fprintf(&b, "STAT version 1.6.26-fake\r\n")
+	fmt.Fprintf(&b, "STAT curr_items %d\r\n", len(s.items))
+	for _, name := range []string{"cmd_get", "cmd_set", "cmd_flush", "get_hits", "get_misses"} {
+		fmt.Fprintf(&b, "STAT %s %d\r\n", name, s.stats[name])
+	}
+	b.WriteString("END\r\n")
+	return b.String()
+}
+
+func (s *fakeMemcachedServer) slabStats() string {
+	var b strings.Builder
+	if len(s.items) > 0 {
+		fmt.Fprintf(&b, "STAT 1:chunk_size 96\r\n")
+		fmt.Fprintf(&b, "STAT 1:used_chunks %d\r\n", len(s.items))
+	}
+	fmt.Fprintf(&b, "STAT active_slabs %d\r\n", min(len(s.items), 1))
+	b.WriteString("END\r\n")
+	return b.String()
+}
+
+func (s *fakeMemcachedServer) get(keys []string) string {
+	var b strings.Builder
+	for _, key := range keys {
+		s.stats["cmd_get"]++
+		value, ok := s.items[key]
+		if !ok {
+			s.stats["get_misses"]++
+			continue
+		}
+		s.stats["get_hits"]++
+		fmt.Fprintf(&b, "VALUE %s 0 %d\r\n%s\r\n", key, len(value), value)
+	}
+	b.WriteString("END\r\n")
+	return b.String()
+}
+
+// set stores the data block of 'set <key> <flags> <exptime> <bytes> [noreply]',
+// flags and exptime are ignored.
+func (s *fakeMemcachedServer) set(fields []string, data []byte) string {
+	s.items[fields[1]] = string(data)
+	s.stats["cmd_set"]++
+	if len(fields) > 5 && fields[5] == "noreply" {
+		return ""
+	}
+	return "STORED\r\n"
+}
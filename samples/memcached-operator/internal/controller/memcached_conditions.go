@@ -0,0 +1,147 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	cachev1alpha1 "example.com/m/v2/api/v1alpha1"
+)
+
+// Reasons of the Available, Progressing and Degraded conditions.
+const (
+	reasonReconciling                = "Reconciling"
+	reasonReconcileFailed            = "ReconcileFailed"
+	reasonMinimumReplicasAvailable   = "MinimumReplicasAvailable"
+	reasonMinimumReplicasUnavailable = "MinimumReplicasUnavailable"
+	reasonRolloutInProgress          = "RolloutInProgress"
+	reasonRolloutComplete            = "RolloutComplete"
+	reasonImagePullFailed            = "ImagePullFailed"
+	reasonResizeFailed               = "ResizeFailed"
+	reasonAsExpected                 = "AsExpected"
+)
+
+// setCondition sets the condition for the current generation of the Memcached.
+func setCondition(
+	memcached *cachev1alpha1.Memcached,
+	conditionType string,
+	status metav1.ConditionStatus,
+	reason, message string,
+) {
+	meta.SetStatusCondition(&memcached.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: memcached.Generation,
+	})
+}
+
+// setUnknownConditions marks all conditions as unknown before the first
+// reconciliation.
+func setUnknownConditions(memcached *cachev1alpha1.Memcached) {
+	for _, conditionType := range []string{typeAvailableMemcached, typeProgressingMemcached, typeDegradedMemcached} {
+		setCondition(memcached, conditionType, metav1.ConditionUnknown, reasonReconciling, "Starting reconciliation")
+	}
+}
+
+// setRolloutConditions sets Available and Progressing from the status of the
+// Deployment. Memcached is available as long as the Deployment keeps its
+// minimum of available replicas, e.g. during a rolling restart.
+func setRolloutConditions(memcached *cachev1alpha1.Memcached, dep *appsv1.Deployment) {
+	replicas, available := ptr.Deref(dep.Spec.Replicas, 1), dep.Status.AvailableReplicas
+	message := fmt.Sprintf("%d/%d replicas available", available, replicas)
+	if available >= minAvailable(dep) {
+		setCondition(memcached, typeAvailableMemcached, metav1.ConditionTrue, reasonMinimumReplicasAvailable, message)
+	} else {
+		setCondition(memcached, typeAvailableMemcached, metav1.ConditionFalse, reasonMinimumReplicasUnavailable, message)
+	}
+
+	switch image := dep.Annotations[inPlaceUpdateAnnotation]; {
+	case image != "":
+		setCondition(memcached, typeProgressingMemcached, metav1.ConditionTrue, reasonRolloutInProgress,
+			fmt.Sprintf("Updating the pods in place to %s, %d available", image, available))
+	case rolloutComplete(dep):
+		setCondition(memcached, typeProgressingMemcached, metav1.ConditionFalse, reasonRolloutComplete,
+			fmt.Sprintf("All %d replicas are updated and available", replicas))
+	default:
+		setCondition(memcached, typeProgressingMemcached, metav1.ConditionTrue, reasonRolloutInProgress,
+			fmt.Sprintf("%d/%d replicas updated, %d available", dep.Status.UpdatedReplicas, replicas, available))
+	}
+}
+
+// minAvailable returns the replicas the Deployment keeps available during a
+// rolling update, the same way the Deployment controller computes it.
+func minAvailable(dep *appsv1.Deployment) int32 {
+	replicas := ptr.Deref(dep.Spec.Replicas, 1)
+	rolling := dep.Spec.Strategy.RollingUpdate
+	if dep.Spec.Strategy.Type != appsv1.RollingUpdateDeploymentStrategyType ||
+		rolling == nil || rolling.MaxUnavailable == nil {
+		return replicas
+	}
+
+	maxUnavailable, err := intstr.GetScaledValueFromIntOrPercent(rolling.MaxUnavailable, int(replicas), false)
+	if err != nil {
+		return replicas
+	}
+
+	return replicas - min(int32(maxUnavailable), replicas)
+}
+
+// setDegradedCondition sets Degraded if pods can't pull the memcached image,
+// e.g. because the registry mirror is missing the image or the pull secret is
+// wrong.
+func (r *MemcachedReconciler) setDegradedCondition(memcached *cachev1alpha1.Memcached, pods []corev1.Pod) {
+	failing := podsFailingImagePull(pods)
+	if len(failing) == 0 {
+		setCondition(memcached, typeDegradedMemcached, metav1.ConditionFalse, reasonAsExpected,
+			"All pods are running the memcached image")
+		return
+	}
+
+	setCondition(memcached, typeDegradedMemcached, metav1.ConditionTrue, reasonImagePullFailed,
+		fmt.Sprintf("Pods failing to pull image (%s): %s", r.image(), strings.Join(failing, ", ")))
+}
+
+// updateStatus writes the status of the Memcached. If the Memcached has been
+// modified since it was read, the status is written to the latest version.
+func (r *MemcachedReconciler) updateStatus(ctx context.Context, memcached *cachev1alpha1.Memcached) error {
+	log := logf.FromContext(ctx)
+
+	status := memcached.Status.DeepCopy()
+	err := r.k8.StatusUpdateWithRetry(ctx, memcached, func() error {
+		memcached.Status = *status
+		return nil
+	})
+	if err != nil {
+		log.Error(err, "Failed to update Memcached status")
+		r.statusUpdateFailed(memcached, err)
+		return err
+	}
+
+	return nil
+}
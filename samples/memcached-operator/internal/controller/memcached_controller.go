@@ -19,54 +19,113 @@ package controller
 import (
 	"context"
 	"fmt"
-	"time"
+	"sort"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	cachev1alpha1 "example.com/m/v2/api/v1alpha1"
 	"example.com/m/v2/internal/controller/infra"
 )
 
-const typeAvailableMemcached = "Available"
+const (
+	typeAvailableMemcached   = "Available"
+	typeProgressingMemcached = "Progressing"
+	typeDegradedMemcached    = "Degraded"
+	typePausedMemcached      = "Paused"
+)
+
+// pausedAnnotation set to "true" stops the operator from changing anything but
+// the status of the Memcached resource.
+const pausedAnnotation = "cache.example.com/paused"
+
+const (
+	memcachedImage         = "memcached:1.6.26-alpine3.19"
+	memcachedContainerName = "memcached"
+)
 
 type ownerRefFn func(metav1.Object, metav1.Object, *runtime.Scheme, ...controllerutil.OwnerReferenceOption) error
 
 // MemcachedReconciler reconciles a Memcached object
 type MemcachedReconciler struct {
-	scheme *runtime.Scheme
-	own    ownerRefFn
-	k8     *infra.K8CliImpl
+	scheme  *runtime.Scheme
+	own     ownerRefFn
+	k8      *infra.K8CliImpl
+	mirrors RegistryMirrors
+	mc      *infra.MemcachedCliImpl
+	events  *infra.EventRecorderImpl
+	clock   *infra.ClockImpl
 }
 
-func NewReconciler(scheme *runtime.Scheme, k8 client.Client, ownerRefFor ownerRefFn) *MemcachedReconciler {
+// NewReconciler returns a reconciler emitting an event for every decision with
+// the recorder, e.g. the one of the manager.
+func NewReconciler(
+	scheme *runtime.Scheme,
+	k8 client.Client,
+	rec record.EventRecorder,
+	ownerRefFor ownerRefFn,
+) *MemcachedReconciler {
 	return &MemcachedReconciler{
-		scheme,
-		ownerRefFor,
-		infra.NewK8CliImpl(k8),
+		scheme: scheme,
+		own:    ownerRefFor,
+		k8:     infra.NewK8CliImpl(k8),
+		mc:     infra.NewMemcachedCliImpl(memcachedCommandTimeout),
+		events: infra.NewEventRecorderImpl(rec),
+		clock:  infra.NewClockImpl(),
 	}
 }
 
+// NewReconcilerNull returns a reconciler whose k8 client is the Embedded Stub
+// returning the errors of the map before the k8 client answers the calls.
 func NewReconcilerNull(
 	scheme *runtime.Scheme,
 	k8 client.Client,
 	ownerRefFor ownerRefFn,
 	errMap infra.StubErrors,
 ) *MemcachedReconciler {
+	return NewReconcilerNullWithConfig(scheme, ownerRefFor, infra.StubConfig{Errors: errMap, K8: k8})
+}
+
+// NewReconcilerNullWithConfig returns a reconciler whose k8 client is the
+// Embedded Stub configured by the stub config, e.g. with errors, responses,
+// faults and the objects of the in-memory object store. The scheme of the
+// reconciler is the scheme of the stub. The clock of the nulled reconcilers
+// stands still until the test advances it.
+func NewReconcilerNullWithConfig(scheme *runtime.Scheme, ownerRefFor ownerRefFn, stub infra.StubConfig) *MemcachedReconciler {
+	stub.Scheme = scheme
 	return &MemcachedReconciler{
-		scheme,
-		ownerRefFor,
-		infra.NewK8CliStub(errMap, k8)}
+		scheme: scheme,
+		own:    ownerRefFor,
+		k8:     infra.NewK8CliStubWithConfig(stub),
+		mc:     infra.NewMemcachedCliStub(nil),
+		events: infra.NewEventRecorderStub(),
+		clock:  infra.NewClockStub(),
+	}
+}
+
+// WithRegistryMirrors configures the registries which are used instead of the
+// original registry of the memcached image, e.g. a local kind registry.
+func (r *MemcachedReconciler) WithRegistryMirrors(mirrors RegistryMirrors) *MemcachedReconciler {
+	r.mirrors = mirrors
+	return r
 }
 
 // +kubebuilder:rbac:groups=cache.example.com,resources=memcacheds,verbs=get;list;watch;create;update;patch;delete
@@ -74,7 +133,9 @@ func NewReconcilerNull(
 // +kubebuilder:rbac:groups=cache.example.com,resources=memcacheds/finalizers,verbs=update
 // +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=replicasets,verbs=get;list;watch;update
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -87,6 +148,7 @@ func NewReconcilerNull(
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.20.4/pkg/reconcile
 func (r *MemcachedReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := logf.FromContext(ctx)
+	reconcilesTotal.WithLabelValues(reconcileRan).Inc()
 
 	// Fetch the Memcached instance (CR; remember a CR is like an instance of a CRD)
 	// The purpose is to check if the Custom Resource for the Kind Memcached
@@ -102,179 +164,272 @@ func (r *MemcachedReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 
 		// Error reading the object - requeue the request.
 		log.Error(err, "Failed to get memcached")
-		return requeueWith(err)
+		return r.fail(ctx, nil, err)
 	}
 	log.Info("memcached resource found")
 
+	// Run the cleanup and release the finalizer when the Memcached is deleted
+	if !memcached.DeletionTimestamp.IsZero() {
+		log.Info("memcached resource deleted, cleaning up")
+		return r.reconcileDelete(ctx, memcached)
+	}
+
 	// Let's just set the status to Unknown when no status is available
 	if len(memcached.Status.Conditions) == 0 {
-		if err := r.updateReconcileStatus(ctx, memcached,
-			metav1.ConditionUnknown, "Starting reconciliation"); err != nil {
-			return requeueWith(err)
+		setUnknownConditions(memcached)
+		if err := r.updateStatus(ctx, memcached); err != nil {
+			return r.fail(ctx, memcached, err)
 		}
 
-		// Let's re-fetch the memcached CR after updating the status
-		// so that we have the latest state of the resource on the cluster and we will avoid
-		// raising the error "the object has been modified, please apply
-		// your changes to the latest version and try again" which would re-trigger the reconciliation
-		// if we try to update it again in the following operations
-		if err := r.k8.Get(ctx, req.NamespacedName, memcached); err != nil {
-			log.Error(err, "Failed to re-fetch memcached")
-			return requeueWith(err)
-		}
 		log.Info("no status available, set to Unknown")
 	}
 
-	// Check if the deployment already exists, if not create a new one
-	found := &appsv1.Deployment{}
-	err := r.k8.Get(ctx, types.NamespacedName{Name: memcached.Name, Namespace: memcached.Namespace}, found)
-	if err != nil && apierrors.IsNotFound(err) {
-		// Define a new deployment
-		dep, err := r.deploymentForMemcached(memcached)
-		if err != nil {
-			log.Error(err, "Failed to define new Deployment resource for Memcached")
-
-			// The following implementation will update the status
-			if err := r.updateReconcileStatus(ctx, memcached,
-				metav1.ConditionFalse,
-				fmt.Sprintf("Failed to create Deployment for the custom resource (%s): (%s)", memcached.Name, err),
-			); err != nil {
-				return requeueWith(err)
-			}
-
-			return requeueWith(err)
-		}
+	// Skip all mutations while the reconciliation is paused, e.g. to hand-edit the
+	// Deployment during an incident, but keep the observed status up to date.
+	if isPaused(memcached) {
+		log.Info("reconciliation paused, skipping all mutations", "annotation", pausedAnnotation)
+		return r.reconcilePaused(ctx, memcached)
+	}
 
-		// Create the deployment in the cluster
-		log.Info("Creating a new Deployment",
-			"Deployment.Namespace", dep.Namespace, "Deployment.Name", dep.Name)
-		if err := r.k8.Create(ctx, dep); err != nil {
-			log.Error(err, "Failed to create new Deployment",
-				"Deployment.Namespace", dep.Namespace, "Deployment.Name", dep.Name)
+	if err := r.addFinalizer(ctx, memcached); err != nil {
+		log.Error(err, "Failed to add finalizer")
+		return r.fail(ctx, memcached, err)
+	}
 
-			return requeueWith(err)
-		}
+	// Apply the Deployment with server-side apply. Apply patches need no
+	// resourceVersion, so there are no "the object has been modified" conflicts
+	// with other writers and fields owned by other controllers are kept.
+	found, changed, err := r.reconcileDeployment(ctx, memcached)
+	if err != nil {
+		return r.fail(ctx, memcached, err)
+	}
+	// The Deployment was created or changed. There is no need to requeue, the
+	// watch on the Deployment triggers the next reconciliation as soon as the
+	// Deployment controller reports the new state.
+	if changed {
+		return r.resumed(ctx, memcached)
+	}
+	recordCompletedRestart(memcached, found)
 
-		// Deployment created successfully
-		// We will requeue the reconciliation so that we can ensure the state
-		// and move forward for the next operations
-		return requeueAfterMinute()
-	} else if err != nil {
-		log.Error(err, "Failed to get Deployment")
-		// Let's return the error for the reconciliation to be re-triggered again
-		return requeueWith(err)
+	if err := r.observePods(ctx, memcached); err != nil {
+		log.Error(err, "Failed to list pods for memcached")
+		return r.fail(ctx, memcached, err)
 	}
 
-	// The CRD API defines that the Memcached type have a MemcachedSpec.Size field
-	// to set the quantity of DEployment instances to the desired state on the cluster.
-	// Therefore, the following code will ensure the Deployment size is the same as defined
-	// via the Size spec of the Custom Resource which we are reconciling.
-	log.Info("reconciling size",
-		"Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
-	size := memcached.Spec.Size
-	if *found.Spec.Replicas != size {
-		log.Info(fmt.Sprintf("found diverging size (%d), changing back to (%d)", *found.Spec.Replicas, size),
-			"Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
-		found.Spec.Replicas = &size
-		if err := r.k8.Update(ctx, found); err != nil {
-			log.Error(err, "Failed to update Deployment",
-				"Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
-
-			// Re-fetch the memcached Custom Resource before updating the status
-			// so that we have the latest state of the resource on the cluster and we will avoid
-			// raising the error "the object has been modified, please apply your changes to the
-			// latest version and try again" which would re-trigger the reconciliation
-			if err := r.k8.Get(ctx, req.NamespacedName, memcached); err != nil {
-				log.Error(err, "Failed to re-fetch memcached")
-				return requeueWith(err)
-			}
+	// Reconciliation is active again, the drift was corrected above
+	meta.RemoveStatusCondition(&memcached.Status.Conditions, typePausedMemcached)
 
-			// The following implementation will update the status
-			if err := r.updateResizeStatus(ctx, memcached,
-				metav1.ConditionFalse,
-				fmt.Sprintf("Failed to update the size for the custom resource (%s): (%s)", memcached.Name, err),
-			); err != nil {
-				return requeueWith(err)
-			}
+	// The following implementation will update the status
+	setRolloutConditions(memcached, found)
+	if err := r.updateStatus(ctx, memcached); err != nil {
+		return r.fail(ctx, memcached, err)
+	}
 
-			return requeueWith(err)
-		}
+	return stop()
+}
 
-		// Now, that we update the size we want to requeue the reconciliation
-		// so that we can ensure that we have the latest state of the resource before
-		// update. Also, it will help ensure the desired state on the cluster
-		return requeue()
-	} else {
-		log.Info("all good, no drift in size found",
-			"Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
+func (r *MemcachedReconciler) statusUpdateFailed(memcached *cachev1alpha1.Memcached, err error) {
+	r.events.Eventf(memcached, corev1.EventTypeWarning, "StatusUpdateFailed", "Failed to update status: %s", err)
+}
+
+// resumed removes the Paused condition after the drift of a paused Memcached
+// was corrected. The changed Deployment rolls out until the next
+// reconciliation reports its state.
+func (r *MemcachedReconciler) resumed(ctx context.Context, memcached *cachev1alpha1.Memcached) (ctrl.Result, error) {
+	if !meta.RemoveStatusCondition(&memcached.Status.Conditions, typePausedMemcached) {
+		return stop()
 	}
 
-	// The following implementation will update the status
-	if err := r.updateReconcileStatus(ctx, memcached,
-		metav1.ConditionTrue,
-		fmt.Sprintf("Deployment for custom resource (%s) with %d replicas created successfully", memcached.Name, size),
-	); err != nil {
-		return requeueWith(err)
+	setCondition(memcached, typeProgressingMemcached, metav1.ConditionTrue, reasonRolloutInProgress,
+		"Reconciliation resumed, rolling out the corrected Deployment")
+	if err := r.updateStatus(ctx, memcached); err != nil {
+		return r.fail(ctx, memcached, err)
 	}
 
 	return stop()
 }
 
-func (r *MemcachedReconciler) updateReconcileStatus(
+func isPaused(memcached *cachev1alpha1.Memcached) bool {
+	return memcached.Annotations[pausedAnnotation] == "true"
+}
+
+func (r *MemcachedReconciler) reconcilePaused(
 	ctx context.Context,
 	memcached *cachev1alpha1.Memcached,
-	status metav1.ConditionStatus,
-	message string,
-) error {
+) (ctrl.Result, error) {
 	log := logf.FromContext(ctx)
 
-	meta.SetStatusCondition(
-		&memcached.Status.Conditions,
-		metav1.Condition{
-			Type:    typeAvailableMemcached,
-			Status:  status,
-			Reason:  "Reconciling",
-			Message: message,
-		},
-	)
-	if err := r.k8.StatusUpdate(ctx, memcached); err != nil {
-		log.Error(err, "Failed to update Memcached status")
+	// The Deployment is only read, e.g. to report the rollout of a hand-edited
+	// Deployment. Without a Deployment there is no rollout to report.
+	dep := &appsv1.Deployment{}
+	err := r.k8.Get(ctx, types.NamespacedName{Name: memcached.Name, Namespace: memcached.Namespace}, dep)
+	switch {
+	case err == nil:
+		setRolloutConditions(memcached, dep)
+	case !apierrors.IsNotFound(err):
+		log.Error(err, "Failed to get Deployment")
+		return r.fail(ctx, memcached, err)
+	}
+
+	if err := r.observePods(ctx, memcached); err != nil {
+		log.Error(err, "Failed to list pods for memcached")
+		return r.fail(ctx, memcached, err)
+	}
+
+	setCondition(memcached, typePausedMemcached, metav1.ConditionTrue, "PausedByAnnotation",
+		fmt.Sprintf("Reconciliation is paused by the annotation %s", pausedAnnotation))
+	if err := r.updateStatus(ctx, memcached); err != nil {
+		return r.fail(ctx, memcached, err)
+	}
+
+	return stop()
+}
+
+// observePods updates the pod statuses in the memcached status to surface
+// failing probes, restarts and pods which can't pull the memcached image, e.g.
+// because the registry mirror is missing the image or the pull secret is wrong.
+func (r *MemcachedReconciler) observePods(ctx context.Context, memcached *cachev1alpha1.Memcached) error {
+	pods, err := listPodsForMemcached(ctx, r.k8, memcached)
+	if err != nil {
+		return err
+	}
+	rolledOut, err := r.rolledOutReplicaSets(ctx, memcached)
+	if err != nil {
 		return err
 	}
 
+	memcached.Status.Pods = podStatuses(memcached, pods, rolledOut)
+	if restart := latestRestart(memcached.Status.Pods); restart != nil {
+		memcached.Status.LastRestart = restart
+	}
+	r.setDegradedCondition(memcached, pods)
+
 	return nil
 }
 
-func (r *MemcachedReconciler) updateResizeStatus(
+// listPodsForMemcached returns the pods of the Memcached resource.
+func listPodsForMemcached(
 	ctx context.Context,
+	k8 *infra.K8CliImpl,
 	memcached *cachev1alpha1.Memcached,
-	status metav1.ConditionStatus,
-	message string,
-) error {
-	log := logf.FromContext(ctx)
+) ([]corev1.Pod, error) {
+	pods := &corev1.PodList{}
+	if err := k8.List(ctx, pods,
+		client.InNamespace(memcached.Namespace),
+		client.MatchingLabels(labelsForMemcached(memcached.Name)),
+	); err != nil {
+		return nil, err
+	}
 
-	meta.SetStatusCondition(
-		&memcached.Status.Conditions,
-		metav1.Condition{
-			Type:    typeAvailableMemcached,
-			Status:  status,
-			Reason:  "Resizing",
-			Message: message,
-		},
-	)
-	if err := r.k8.StatusUpdate(ctx, memcached); err != nil {
-		log.Error(err, "Failed to update Memcached status")
-		return err
+	return pods.Items, nil
+}
+
+// podsFailingImagePull returns the names of the pods with a container waiting
+// for its image to be pulled after a failed attempt.
+func podsFailingImagePull(pods []corev1.Pod) []string {
+	var failing []string
+	for _, pod := range pods {
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.State.Waiting == nil {
+				continue
+			}
+			if cs.State.Waiting.Reason == "ImagePullBackOff" || cs.State.Waiting.Reason == "ErrImagePull" {
+				failing = append(failing, pod.Name)
+				break
+			}
+		}
+	}
+
+	return failing
+}
+
+// podStatuses returns the observed state of the memcached container of each
+// pod sorted by pod name. rolledOut holds the ReplicaSets created by rollouts.
+func podStatuses(
+	memcached *cachev1alpha1.Memcached,
+	pods []corev1.Pod,
+	rolledOut map[string]bool,
+) []cachev1alpha1.MemcachedPodStatus {
+	statuses := make([]cachev1alpha1.MemcachedPodStatus, 0, len(pods))
+	for _, pod := range pods {
+		status := cachev1alpha1.MemcachedPodStatus{
+			Name:  pod.Name,
+			Phase: pod.Status.Phase,
+		}
+		if cs := memcachedContainerStatus(pod); cs != nil {
+			status.Ready = cs.Ready
+			status.Started = ptr.Deref(cs.Started, false)
+			status.RestartCount = cs.RestartCount
+			status.Message = containerMessage(cs)
+			status.LastRestart = lastRestartOf(&pod, cs, warmRestartEnabled(memcached), rolledOut[replicaSetOf(&pod)])
+		}
+		statuses = append(statuses, status)
 	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
 
+	return statuses
+}
+
+func memcachedContainerStatus(pod corev1.Pod) *corev1.ContainerStatus {
+	for i := range pod.Status.ContainerStatuses {
+		if pod.Status.ContainerStatuses[i].Name == memcachedContainerName {
+			return &pod.Status.ContainerStatuses[i]
+		}
+	}
 	return nil
 }
 
+// containerMessage explains why the memcached container is not ready. The
+// kubelet only reports probe results as the started and ready flags, failing
+// liveness probes show up as restarts.
+func containerMessage(cs *corev1.ContainerStatus) string {
+	switch {
+	case cs.State.Waiting != nil:
+		return fmt.Sprintf("waiting: %s %s", cs.State.Waiting.Reason, cs.State.Waiting.Message)
+	case cs.State.Terminated != nil:
+		return fmt.Sprintf("terminated: %s (exit code %d)", cs.State.Terminated.Reason, cs.State.Terminated.ExitCode)
+	case cs.State.Running != nil && !ptr.Deref(cs.Started, false):
+		return "startup probe has not succeeded yet"
+	case cs.State.Running != nil && !cs.Ready:
+		return "readiness probe failing"
+	case cs.LastTerminationState.Terminated != nil:
+		last := cs.LastTerminationState.Terminated
+		return fmt.Sprintf("restarted after %s (exit code %d)", last.Reason, last.ExitCode)
+	default:
+		return ""
+	}
+}
+
+// image returns the memcached image rewritten to the configured registry mirror.
+func (r *MemcachedReconciler) image() string {
+	return r.mirrors.Rewrite(memcachedImage)
+}
+
+const (
+	nameLabel     = "app.kubernetes.io/name"
+	instanceLabel = "app.kubernetes.io/instance"
+)
+
+// labelsForMemcached returns the labels selecting the pods of one Memcached resource.
+func labelsForMemcached(name string) map[string]string {
+	return map[string]string{
+		nameLabel:     "project",
+		instanceLabel: name,
+	}
+}
+
 func (r *MemcachedReconciler) deploymentForMemcached(memcached *cachev1alpha1.Memcached) (*appsv1.Deployment, error) {
 	replicas := memcached.Spec.Size
-	image := "memcached:1.6.26-alpine3.19"
+	image := r.image()
+	labels := labelsForMemcached(memcached.Name)
+	readiness, liveness, startup := probesForMemcached(memcached)
+	volumes, volumeMounts := volumesForMemcached(memcached)
 
 	dep := &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: appsv1.SchemeGroupVersion.String(),
+			Kind:       "Deployment",
+		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      memcached.Name,
 			Namespace: memcached.Namespace,
@@ -282,13 +437,15 @@ func (r *MemcachedReconciler) deploymentForMemcached(memcached *cachev1alpha1.Me
 		Spec: appsv1.DeploymentSpec{
 			Replicas: &replicas,
 			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{"app.kubernetes.io/name": "project"},
+				MatchLabels: labels,
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{"app.kubernetes.io/name": "project"},
+					Labels: labels,
 				},
 				Spec: corev1.PodSpec{
+					ImagePullSecrets:              memcached.Spec.ImagePullSecrets,
+					TerminationGracePeriodSeconds: terminationGracePeriodFor(memcached),
 					SecurityContext: &corev1.PodSecurityContext{
 						RunAsNonRoot: ptr.To(true),
 						SeccompProfile: &corev1.SeccompProfile{
@@ -297,7 +454,7 @@ func (r *MemcachedReconciler) deploymentForMemcached(memcached *cachev1alpha1.Me
 					},
 					Containers: []corev1.Container{{
 						Image:           image,
-						Name:            "memcached",
+						Name:            memcachedContainerName,
 						ImagePullPolicy: corev1.PullIfNotPresent,
 						// Ensure restrictive context for the container
 						// More info: https://kubernetes.io/docs/concepts/security/pod-security-standards/#restricted
@@ -312,16 +469,24 @@ func (r *MemcachedReconciler) deploymentForMemcached(memcached *cachev1alpha1.Me
 							},
 						},
 						Ports: []corev1.ContainerPort{{
-							ContainerPort: 11211,
+							ContainerPort: memcachedPort,
 							Name:          "memcached",
 						}},
-						Command: []string{"memcached", "--memory-limit=64", "-o", "modern", "-v"},
+						ReadinessProbe: readiness,
+						LivenessProbe:  liveness,
+						StartupProbe:   startup,
+						Lifecycle:      lifecycleForMemcached(),
+						Command:        commandForMemcached(memcached),
+						VolumeMounts:   volumeMounts,
 					}},
+					Volumes: volumes,
 				},
 			},
 		},
 	}
 
+	stampRestart(memcached, dep)
+
 	// Set the ownerRef for the Deployment. Important so that reconciliation is triggered when the
 	// Deployment of our Memcached Custom Resource is changed and when the Memcached Custom Resource
 	// is deleted all resources owned by it are also automatically deleted.
@@ -337,28 +502,70 @@ func (r *MemcachedReconciler) deploymentForMemcached(memcached *cachev1alpha1.Me
 func (r *MemcachedReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		// Watch the Memcached Custom Resource and trigger reconciliation whenever it
-		// is created, updated, or deleted.
-		For(&cachev1alpha1.Memcached{}).
+		// is created, updated, or deleted. Updates of the status only are skipped,
+		// otherwise every status update of the reconciler would trigger the next one.
+		For(&cachev1alpha1.Memcached{}, builder.WithPredicates(countSkipped(memcachedChanged()))).
 		// Watch the Deployment managed by the Memcached controller. If any changes occur to the
 		// Deployment owned and managed by this controller, it will trigger reconciliation, ensuring
-		// that the cluster state aligns with the desired state.
-		Owns(&appsv1.Deployment{}).
+		// that the cluster state aligns with the desired state. Changes not affecting the
+		// Memcached are skipped.
+		Owns(&appsv1.Deployment{}, builder.WithPredicates(countSkipped(deploymentChanged()))).
+		// Watch the memcached pods, they are owned by the ReplicaSets of the Deployment.
+		// Pod status changes, e.g. failing probes or image pulls, update the status of
+		// the Memcached without polling. Other pods are not counted as skipped, they are
+		// no events of the Memcached, and are kept out of the cache by CacheOptions.
+		Watches(
+			&corev1.Pod{},
+			handler.EnqueueRequestsFromMapFunc(memcachedForPod),
+			builder.WithPredicates(predicate.NewPredicateFuncs(isMemcachedPod)),
+		).
 		Named("memcached").
 		Complete(r)
 }
 
-func stop() (ctrl.Result, error) {
-	return ctrl.Result{}, nil
+// CacheOptions restricts the cache of the manager to the memcached pods and
+// their ReplicaSets, the watch of the pods would cache every pod in the cluster
+// otherwise. The ReplicaSets carry the labels of the pod template.
+func CacheOptions() cache.Options {
+	return cache.Options{
+		ByObject: map[client.Object]cache.ByObject{
+			&corev1.Pod{}:        {Label: memcachedPodSelector()},
+			&appsv1.ReplicaSet{}: {Label: memcachedPodSelector()},
+		},
+	}
 }
 
-func requeueWith(err error) (ctrl.Result, error) {
-	return ctrl.Result{}, err
+// memcachedPodSelector selects the pods labeled by labelsForMemcached.
+func memcachedPodSelector() labels.Selector {
+	name, _ := labels.NewRequirement(nameLabel, selection.Equals, []string{"project"})
+	instance, _ := labels.NewRequirement(instanceLabel, selection.Exists, nil)
+	return labels.NewSelector().Add(*name, *instance)
+}
+
+// isMemcachedPod is true for pods labeled by labelsForMemcached.
+func isMemcachedPod(obj client.Object) bool {
+	labels := obj.GetLabels()
+	return labels[nameLabel] == "project" && labels[instanceLabel] != ""
+}
+
+// memcachedForPod maps a memcached pod to the Memcached it belongs to.
+func memcachedForPod(_ context.Context, obj client.Object) []reconcile.Request {
+	if !isMemcachedPod(obj) {
+		return nil
+	}
+
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{
+		Name:      obj.GetLabels()[instanceLabel],
+		Namespace: obj.GetNamespace(),
+	}}}
 }
 
-func requeue() (ctrl.Result, error) {
-	return ctrl.Result{Requeue: true}, nil
+func stop() (ctrl.Result, error) {
+	return ctrl.Result{}, nil
 }
 
-func requeueAfterMinute() (ctrl.Result, error) {
-	return ctrl.Result{RequeueAfter: time.Minute}, nil
+// requeueWith requeues the request with backoff, use fail to stop on terminal
+// errors.
+func requeueWith(err error) (ctrl.Result, error) {
+	return ctrl.Result{}, err
 }
@@ -0,0 +1,755 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cachev1alpha1 "example.com/m/v2/api/v1alpha1"
+	"example.com/m/v2/internal/controller/infra"
+)
+
+// The tests in this file run the nulled reconciler against the in-memory object
+// store of the Embedded Stub. They need no envtest binaries and run in
+// milliseconds, e.g. with 'go test -run Test_Null ./internal/controller/'.
+
+var nullName = types.NamespacedName{Name: "null-memcached", Namespace: "default"}
+
+func Test_Null_createsDeploymentAndFinalizer(t *testing.T) {
+	r := newNullReconciler(t, newMemcached(nullName))
+
+	reconcileNull(t, r)
+
+	memcached := getNull(t, r, &cachev1alpha1.Memcached{})
+	if !slices.Contains(memcached.Finalizers, memcachedFinalizer) {
+		t.Errorf("expected finalizer %s, got %v", memcachedFinalizer, memcached.Finalizers)
+	}
+	expectNullCondition(t, memcached, typeAvailableMemcached, metav1.ConditionUnknown, reasonReconciling)
+
+	dep := getNull(t, r, &appsv1.Deployment{})
+	if got := ptr.Deref(dep.Spec.Replicas, 0); got != 1 {
+		t.Errorf("expected 1 replica, got %d", got)
+	}
+	if owner := metav1.GetControllerOf(dep); owner == nil || owner.UID != memcached.UID {
+		t.Errorf("expected deployment controlled by the memcached, got %v", dep.OwnerReferences)
+	}
+}
+
+func Test_Null_reportsAvailability(t *testing.T) {
+	r := newNullReconciler(t, newMemcached(nullName))
+	reconcileNull(t, r)
+
+	reconcileNull(t, r)
+	expectNullCondition(t, getNull(t, r, &cachev1alpha1.Memcached{}),
+		typeAvailableMemcached, metav1.ConditionFalse, reasonMinimumReplicasUnavailable)
+
+	setNullDeploymentStatus(t, r, 1)
+
+	reconcileNull(t, r)
+	memcached := getNull(t, r, &cachev1alpha1.Memcached{})
+	expectNullCondition(t, memcached, typeAvailableMemcached, metav1.ConditionTrue, reasonMinimumReplicasAvailable)
+	expectNullCondition(t, memcached, typeProgressingMemcached, metav1.ConditionFalse, reasonRolloutComplete)
+	expectNullCondition(t, memcached, typeDegradedMemcached, metav1.ConditionFalse, reasonAsExpected)
+}
+
+func Test_Null_revertsResizedDeployment(t *testing.T) {
+	r := newNullReconciler(t, newMemcached(nullName))
+	events := r.events.TrackEvents()
+	reconcileNull(t, r)
+
+	dep := getNull(t, r, &appsv1.Deployment{})
+	dep.Spec.Replicas = ptr.To(int32(3))
+	if err := r.k8.Update(context.Background(), dep); err != nil {
+		t.Fatalf("unexpected error resizing deployment %v", err)
+	}
+
+	reconcileNull(t, r)
+
+	if got := ptr.Deref(getNull(t, r, &appsv1.Deployment{}).Spec.Replicas, 0); got != 1 {
+		t.Errorf("expected 1 replica, got %d", got)
+	}
+	expected := infra.Event{
+		Type:    corev1.EventTypeNormal,
+		Reason:  "DriftCorrected",
+		Message: "Resized Deployment null-memcached from 3 back to 1 replicas",
+	}
+	if !slices.Contains(events.Data(), expected) {
+		t.Errorf("expected event %v, got %v", expected, events.Data())
+	}
+}
+
+func Test_Null_reportsRolloutOfRestart(t *testing.T) {
+	r := newNullReconciler(t, newMemcached(nullName))
+	reconcileNull(t, r)
+	reconcileNull(t, r)
+	setNullDeploymentStatus(t, r, 1)
+
+	memcached := getNull(t, r, &cachev1alpha1.Memcached{})
+	restartedAt := metav1.NewTime(time.Now().Truncate(time.Second))
+	memcached.Spec.RestartedAt = &restartedAt
+	if err := r.k8.Update(context.Background(), memcached); err != nil {
+		t.Fatalf("unexpected error restarting memcached %v", err)
+	}
+	generation := getNull(t, r, &appsv1.Deployment{}).Generation
+
+	// the stamped template is a new generation the Deployment controller hasn't
+	// observed yet
+	reconcileNull(t, r)
+	if got := getNull(t, r, &appsv1.Deployment{}).Generation; got != generation+1 {
+		t.Fatalf("expected generation %d, got %d", generation+1, got)
+	}
+	reconcileNull(t, r)
+	memcached = getNull(t, r, &cachev1alpha1.Memcached{})
+	expectNullCondition(t, memcached, typeProgressingMemcached, metav1.ConditionTrue, reasonRolloutInProgress)
+	if memcached.Status.LastCompletedRestartAt != nil {
+		t.Errorf("expected no completed restart during the rollout, got %v", memcached.Status.LastCompletedRestartAt)
+	}
+
+	setNullDeploymentStatus(t, r, 1)
+	reconcileNull(t, r)
+
+	memcached = getNull(t, r, &cachev1alpha1.Memcached{})
+	expectNullCondition(t, memcached, typeProgressingMemcached, metav1.ConditionFalse, reasonRolloutComplete)
+	if got := memcached.Status.LastCompletedRestartAt; got == nil || !got.Equal(&restartedAt) {
+		t.Errorf("expected the completed restart at %v, got %v", restartedAt, got)
+	}
+}
+
+func Test_Null_keepsSelectorOfExistingDeployment(t *testing.T) {
+	r := newNullReconciler(t, newMemcached(nullName))
+	reconcileNull(t, r)
+
+	// created by an operator version selecting the pods by the name label only
+	oldLabels := map[string]string{nameLabel: "project"}
+	dep := getNull(t, r, &appsv1.Deployment{})
+	dep.Spec.Selector = &metav1.LabelSelector{MatchLabels: oldLabels}
+	dep.Spec.Template.Labels = oldLabels
+	if err := r.k8.Update(context.Background(), dep); err != nil {
+		t.Fatalf("unexpected error updating deployment %v", err)
+	}
+
+	reconcileNull(t, r)
+
+	dep = getNull(t, r, &appsv1.Deployment{})
+	if got := dep.Spec.Selector.MatchLabels; !maps.Equal(got, oldLabels) {
+		t.Errorf("expected the immutable selector %v to be kept, got %v", oldLabels, got)
+	}
+	if got := dep.Spec.Template.Labels; !maps.Equal(got, labelsForMemcached(nullName.Name)) {
+		t.Errorf("expected the pods labeled with %v, got %v", labelsForMemcached(nullName.Name), got)
+	}
+}
+
+func Test_Null_keepsResizedDeploymentWhilePaused(t *testing.T) {
+	memcached := newMemcached(nullName)
+	r := newNullReconciler(t, memcached)
+	reconcileNull(t, r)
+
+	memcached = getNull(t, r, &cachev1alpha1.Memcached{})
+	memcached.Annotations = map[string]string{pausedAnnotation: "true"}
+	if err := r.k8.Update(context.Background(), memcached); err != nil {
+		t.Fatalf("unexpected error pausing memcached %v", err)
+	}
+	dep := getNull(t, r, &appsv1.Deployment{})
+	dep.Spec.Replicas = ptr.To(int32(3))
+	if err := r.k8.Update(context.Background(), dep); err != nil {
+		t.Fatalf("unexpected error resizing deployment %v", err)
+	}
+
+	reconcileNull(t, r)
+
+	if got := ptr.Deref(getNull(t, r, &appsv1.Deployment{}).Spec.Replicas, 0); got != 3 {
+		t.Errorf("expected 3 replicas while paused, got %d", got)
+	}
+	expectNullCondition(t, getNull(t, r, &cachev1alpha1.Memcached{}),
+		typePausedMemcached, metav1.ConditionTrue, "PausedByAnnotation")
+}
+
+func Test_Null_reportsRolloutWhilePaused(t *testing.T) {
+	memcached := newMemcached(nullName)
+	r := newNullReconciler(t, memcached)
+	reconcileNull(t, r)
+
+	memcached = getNull(t, r, &cachev1alpha1.Memcached{})
+	memcached.Annotations = map[string]string{pausedAnnotation: "true"}
+	if err := r.k8.Update(context.Background(), memcached); err != nil {
+		t.Fatalf("unexpected error pausing memcached %v", err)
+	}
+	reconcileNull(t, r)
+	expectNullCondition(t, getNull(t, r, &cachev1alpha1.Memcached{}),
+		typeAvailableMemcached, metav1.ConditionFalse, reasonMinimumReplicasUnavailable)
+
+	setNullDeploymentStatus(t, r, 1)
+
+	reconcileNull(t, r)
+
+	memcached = getNull(t, r, &cachev1alpha1.Memcached{})
+	expectNullCondition(t, memcached, typeAvailableMemcached, metav1.ConditionTrue, reasonMinimumReplicasAvailable)
+	expectNullCondition(t, memcached, typeProgressingMemcached, metav1.ConditionFalse, reasonRolloutComplete)
+	expectNullCondition(t, memcached, typePausedMemcached, metav1.ConditionTrue, "PausedByAnnotation")
+}
+
+func Test_Null_removesPausedWhenCorrectingDriftAfterResume(t *testing.T) {
+	memcached := newMemcached(nullName)
+	memcached.Annotations = map[string]string{pausedAnnotation: "true"}
+	r := newNullReconciler(t, memcached)
+	reconcileNull(t, r)
+	expectNullCondition(t, getNull(t, r, &cachev1alpha1.Memcached{}),
+		typePausedMemcached, metav1.ConditionTrue, "PausedByAnnotation")
+
+	memcached = getNull(t, r, &cachev1alpha1.Memcached{})
+	delete(memcached.Annotations, pausedAnnotation)
+	if err := r.k8.Update(context.Background(), memcached); err != nil {
+		t.Fatalf("unexpected error resuming memcached %v", err)
+	}
+
+	// the first reconcile after the resume creates the Deployment
+	reconcileNull(t, r)
+
+	memcached = getNull(t, r, &cachev1alpha1.Memcached{})
+	if cond := meta.FindStatusCondition(memcached.Status.Conditions, typePausedMemcached); cond != nil {
+		t.Errorf("expected no %s condition after resuming, got %v", typePausedMemcached, cond)
+	}
+	expectNullCondition(t, memcached, typeProgressingMemcached, metav1.ConditionTrue, reasonRolloutInProgress)
+}
+
+func Test_Null_enablesShutdownOnlyWithWarmRestart(t *testing.T) {
+	r := newNullReconciler(t, newMemcached(nullName))
+	reconcileNull(t, r)
+
+	command := getNull(t, r, &appsv1.Deployment{}).Spec.Template.Spec.Containers[0].Command
+	if slices.Contains(command, "-A") {
+		t.Errorf("expected the shutdown command to be disabled, got %v", command)
+	}
+
+	memcached := getNull(t, r, &cachev1alpha1.Memcached{})
+	memcached.Spec.WarmRestart = &cachev1alpha1.WarmRestartSpec{Enabled: true}
+	if err := r.k8.Update(context.Background(), memcached); err != nil {
+		t.Fatalf("unexpected error enabling warm restart %v", err)
+	}
+	reconcileNull(t, r)
+
+	command = getNull(t, r, &appsv1.Deployment{}).Spec.Template.Spec.Containers[0].Command
+	if !slices.Contains(command, "-A") {
+		t.Errorf("expected the shutdown command to be enabled with warm restart, got %v", command)
+	}
+}
+
+func Test_Null_recordsOnlyContainerRestarts(t *testing.T) {
+	memcached := newMemcached(nullName)
+	memcached.Spec.WarmRestart = &cachev1alpha1.WarmRestartSpec{Enabled: true}
+	startedAt := metav1.NewTime(time.Now().Truncate(time.Second))
+	runningPod := func(name string, restarts int32, last *corev1.ContainerStateTerminated) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: nullName.Namespace, Labels: labelsForMemcached(nullName.Name)},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: []corev1.ContainerStatus{{
+				Name:                 memcachedContainerName,
+				RestartCount:         restarts,
+				State:                corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: startedAt}},
+				LastTerminationState: corev1.ContainerState{Terminated: last},
+			}}},
+		}
+	}
+	r := newNullReconciler(t, memcached,
+		runningPod("first-start", 0, nil),
+		runningPod("crashed", 1, &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}),
+	)
+	reconcileNull(t, r)
+
+	reconcileNull(t, r)
+
+	memcached = getNull(t, r, &cachev1alpha1.Memcached{})
+	for _, pod := range memcached.Status.Pods {
+		if pod.Name == "first-start" && pod.LastRestart != nil {
+			t.Errorf("expected no restart of a pod which just started, got %v", pod.LastRestart)
+		}
+	}
+	restart := memcached.Status.LastRestart
+	if restart == nil || restart.Pod != "crashed" || restart.Type != cachev1alpha1.RestartTypeCold {
+		t.Errorf("expected the cold restart of the crashed pod, got %v", restart)
+	}
+}
+
+func Test_Null_recordsColdStartOfRolledOutPods(t *testing.T) {
+	memcached := newMemcached(nullName)
+	memcached.Spec.WarmRestart = &cachev1alpha1.WarmRestartSpec{Enabled: true}
+	startedAt := metav1.NewTime(time.Now().Truncate(time.Second))
+	first, rolledOut := newNullReplicaSet("first", 1), newNullReplicaSet("rolled-out", 2)
+	r := newNullReconciler(t, memcached, first, rolledOut,
+		newNullPod("initial", first, memcachedImage, runningSince(startedAt)),
+		newNullPod("replacement", rolledOut, memcachedImage, runningSince(startedAt)),
+	)
+	reconcileNull(t, r)
+
+	reconcileNull(t, r)
+
+	memcached = getNull(t, r, &cachev1alpha1.Memcached{})
+	for _, pod := range memcached.Status.Pods {
+		if pod.Name == "initial" && pod.LastRestart != nil {
+			t.Errorf("expected no restart of a pod of the first revision, got %v", pod.LastRestart)
+		}
+	}
+	restart := memcached.Status.LastRestart
+	if restart == nil || restart.Pod != "replacement" || restart.Type != cachev1alpha1.RestartTypeCold {
+		t.Errorf("expected the cold start of the rolled out pod, got %v", restart)
+	}
+}
+
+func Test_Null_updatesNewImageInPlaceWithWarmRestart(t *testing.T) {
+	ctx := context.Background()
+	memcached := newMemcached(nullName)
+	memcached.Spec.Size = 2
+	memcached.Spec.WarmRestart = &cachev1alpha1.WarmRestartSpec{Enabled: true}
+	r := newNullReconciler(t, memcached)
+	reconcileNull(t, r)
+	reconcileNull(t, r)
+	setNullDeploymentStatus(t, r, 2)
+
+	rs := newNullReplicaSet("first", 1)
+	rs.Spec.Template = *getNull(t, r, &appsv1.Deployment{}).Spec.Template.DeepCopy()
+	startedAt := metav1.NewTime(r.clock.Now().Add(-time.Hour))
+	for _, obj := range []client.Object{
+		rs,
+		newNullPod("pod-a", rs, memcachedImage, runningSince(startedAt)),
+		newNullPod("pod-b", rs, memcachedImage, runningSince(startedAt)),
+	} {
+		if err := r.k8.Create(ctx, obj); err != nil {
+			t.Fatalf("unexpected error creating %T %v", obj, err)
+		}
+	}
+
+	// a new image, e.g. of a new operator version
+	r.WithRegistryMirrors(RegistryMirrors{"docker.io": "localhost:5000"})
+	image := r.image()
+	events := r.events.TrackEvents()
+
+	reconcileNull(t, r)
+
+	dep := getNull(t, r, &appsv1.Deployment{})
+	if !dep.Spec.Paused || dep.Annotations[inPlaceUpdateAnnotation] != image {
+		t.Errorf("expected deployment paused for the update to %s, got paused %t and annotations %v",
+			image, dep.Spec.Paused, dep.Annotations)
+	}
+	expectNullImages(t, r, map[string]string{"pod-a": image, "pod-b": memcachedImage})
+	expectNullCondition(t, getNull(t, r, &cachev1alpha1.Memcached{}),
+		typeProgressingMemcached, metav1.ConditionTrue, reasonRolloutInProgress)
+
+	// pod-a still runs the container of the old image
+	reconcileNull(t, r)
+	expectNullImages(t, r, map[string]string{"pod-a": image, "pod-b": memcachedImage})
+
+	restartNullPodGracefully(t, r, "pod-a")
+	reconcileNull(t, r)
+	expectNullImages(t, r, map[string]string{"pod-a": image, "pod-b": image})
+
+	r.clock.Advance(time.Minute)
+	restartNullPodGracefully(t, r, "pod-b")
+	reconcileNull(t, r)
+
+	if err := r.k8.Get(ctx, client.ObjectKeyFromObject(rs), rs); err != nil {
+		t.Fatalf("unexpected error getting replicaset %v", err)
+	}
+	if got := rs.Spec.Template.Spec.Containers[0].Image; got != image {
+		t.Errorf("expected replicaset with image %s, got %s", image, got)
+	}
+	expected := infra.Event{
+		Type:    corev1.EventTypeNormal,
+		Reason:  "UpdatedInPlace",
+		Message: fmt.Sprintf("Updated the 2 pods of Deployment null-memcached in place to %s", image),
+	}
+	if !slices.Contains(events.Data(), expected) {
+		t.Errorf("expected event %v, got %v", expected, events.Data())
+	}
+
+	// the apply removed the annotation and paused, the object store doesn't
+	// remove fields the operator stopped applying
+	dep = getNull(t, r, &appsv1.Deployment{})
+	delete(dep.Annotations, inPlaceUpdateAnnotation)
+	dep.Spec.Paused = false
+	if err := r.k8.Update(ctx, dep); err != nil {
+		t.Fatalf("unexpected error resuming deployment %v", err)
+	}
+	setNullDeploymentStatus(t, r, 2)
+	reconcileNull(t, r)
+
+	memcached = getNull(t, r, &cachev1alpha1.Memcached{})
+	expectNullCondition(t, memcached, typeProgressingMemcached, metav1.ConditionFalse, reasonRolloutComplete)
+	restart := memcached.Status.LastRestart
+	if restart == nil || restart.Pod != "pod-b" || restart.Type != cachev1alpha1.RestartTypeWarm {
+		t.Errorf("expected the warm restart of pod-b, got %v", restart)
+	}
+}
+
+func Test_Null_releasesFinalizerOnDeletion(t *testing.T) {
+	r := newNullReconciler(t, newMemcached(nullName))
+	reconcileNull(t, r)
+
+	if err := r.k8.Delete(context.Background(), getNull(t, r, &cachev1alpha1.Memcached{})); err != nil {
+		t.Fatalf("unexpected error deleting memcached %v", err)
+	}
+	reconcileNull(t, r)
+
+	err := r.k8.Get(context.Background(), nullName, &cachev1alpha1.Memcached{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("expected memcached to be gone, got %v", err)
+	}
+}
+
+func Test_Null_releasesFinalizerAfterCleanupTimeout(t *testing.T) {
+	memcached := newMemcached(nullName)
+	memcached.Spec.FlushOnDelete = true
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "null-memcached-pod",
+			Namespace: nullName.Namespace,
+			Labels:    labelsForMemcached(nullName.Name),
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.0.0.1"},
+	}
+	r := newNullReconciler(t, memcached, pod)
+	memcachedServer := newMemcachedStub(nil)
+	memcachedServer.failFor("10.0.0.1:11211", "flush_all")
+	memcachedServer.failFor("10.0.0.1:11211", "flush_all")
+	r.mc = memcachedServer.cli
+	reconcileNull(t, r)
+
+	if err := r.k8.Delete(context.Background(), getNull(t, r, &cachev1alpha1.Memcached{})); err != nil {
+		t.Fatalf("unexpected error deleting memcached %v", err)
+	}
+	if _, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: nullName}); err == nil {
+		t.Fatal("expected the failed cleanup to be retried, got nothing")
+	}
+	expectNullCondition(t, getNull(t, r, &cachev1alpha1.Memcached{}),
+		typeCleanupFailedMemcached, metav1.ConditionTrue, "CleanupFailed")
+
+	r.clock.Advance(cleanupTimeout + time.Second)
+	reconcileNull(t, r)
+
+	err := r.k8.Get(context.Background(), nullName, &cachev1alpha1.Memcached{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("expected memcached to be gone after the cleanup timeout, got %v", err)
+	}
+}
+
+func Test_Null_deletesOnlyClaimsOfTheMemcached(t *testing.T) {
+	memcached := newMemcached(nullName)
+	memcached.UID = "null-memcached-uid"
+	memcached.Spec.DeletionPolicy = cachev1alpha1.DeletionPolicyDelete
+	claim := func(name string, annotations map[string]string, owners ...metav1.OwnerReference) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       nullName.Namespace,
+			Labels:          labelsForMemcached(nullName.Name),
+			Annotations:     annotations,
+			OwnerReferences: owners,
+		}}
+	}
+	r := newNullReconciler(t, memcached,
+		claim("owned", nil, metav1.OwnerReference{
+			APIVersion: cachev1alpha1.GroupVersion.String(), Kind: "Memcached", Name: nullName.Name, UID: memcached.UID,
+		}),
+		claim("annotated", map[string]string{claimAnnotation: nullName.Name}),
+		claim("labeled", nil),
+		claim("annotated-for-other", map[string]string{claimAnnotation: "other-memcached"}),
+	)
+	reconcileNull(t, r)
+
+	if err := r.k8.Delete(context.Background(), getNull(t, r, &cachev1alpha1.Memcached{})); err != nil {
+		t.Fatalf("unexpected error deleting memcached %v", err)
+	}
+	reconcileNull(t, r)
+
+	for name, deleted := range map[string]bool{"owned": true, "annotated": true, "labeled": false, "annotated-for-other": false} {
+		err := r.k8.Get(context.Background(), types.NamespacedName{Name: name, Namespace: nullName.Namespace},
+			&corev1.PersistentVolumeClaim{})
+		if deleted != apierrors.IsNotFound(err) {
+			t.Errorf("expected claim %s deleted to be %t, got %v", name, deleted, err)
+		}
+	}
+}
+
+func Test_Null_stopsOnOwnershipConflict(t *testing.T) {
+	other := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+		Name:      nullName.Name,
+		Namespace: nullName.Namespace,
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: "v1", Kind: "ConfigMap", Name: "other-owner", UID: "other-owner-uid", Controller: ptr.To(true),
+		}},
+	}}
+	r := newNullReconciler(t, newMemcached(nullName), other)
+
+	_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: nullName})
+	if !errors.Is(err, reconcile.TerminalError(nil)) {
+		t.Fatalf("expected terminal error, got %v", err)
+	}
+
+	expectNullCondition(t, getNull(t, r, &cachev1alpha1.Memcached{}),
+		typeDegradedMemcached, metav1.ConditionTrue, reasonOwnershipConflict)
+}
+
+func Test_Null_reportsFailedDeploymentWrite(t *testing.T) {
+	forbidden := apierrors.NewForbidden(appsv1.Resource("deployments"), nullName.Name, errors.New("denied"))
+	r := NewReconcilerNullWithConfig(nullScheme(t), ctrl.SetControllerReference, infra.StubConfig{
+		Faults: infra.StubFaults{
+			{Method: "Apply", GVK: appsv1.SchemeGroupVersion.WithKind("Deployment"), Err: forbidden},
+		},
+		Objects: []client.Object{newMemcached(nullName)},
+	})
+
+	_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: nullName})
+	if !errors.Is(err, reconcile.TerminalError(nil)) {
+		t.Fatalf("expected terminal error, got %v", err)
+	}
+
+	// the status of the memcached is written, only the deployment fails
+	expectNullCondition(t, getNull(t, r, &cachev1alpha1.Memcached{}),
+		typeDegradedMemcached, metav1.ConditionTrue, reasonForbidden)
+	if err := r.k8.Get(context.Background(), nullName, &appsv1.Deployment{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected no deployment, got %v", err)
+	}
+}
+
+func Test_Null_retriesStatusUpdateOnConflict(t *testing.T) {
+	memcachedGVK := cachev1alpha1.GroupVersion.WithKind("Memcached")
+	r := NewReconcilerNullWithConfig(nullScheme(t), ctrl.SetControllerReference, infra.StubConfig{
+		Faults:  infra.StubFaults{infra.ConflictFault("StatusUpdate", memcachedGVK, nullName, 1)},
+		Objects: []client.Object{newMemcached(nullName)},
+	})
+	writes := r.k8.TrackWrites()
+
+	reconcileNull(t, r)
+
+	expectNullCondition(t, getNull(t, r, &cachev1alpha1.Memcached{}),
+		typeAvailableMemcached, metav1.ConditionUnknown, reasonReconciling)
+	if got := writeActions(writes); len(got) == 0 || got[0] != "StatusUpdate *v1alpha1.Memcached" {
+		t.Errorf("expected the status to be written after the conflict, got %v", got)
+	}
+}
+
+// Run with -race, e.g. 'make test-race', to detect data races between
+// concurrent reconciles sharing one nulled k8 client.
+func Test_Null_concurrentReconciles(t *testing.T) {
+	const instances, reconcilesPerInstance = 10, 5
+	var objs []client.Object
+	for i := 0; i < instances; i++ {
+		objs = append(objs, newMemcached(types.NamespacedName{Name: fmt.Sprintf("concurrent-%d", i), Namespace: "default"}))
+	}
+	errMap := infra.StubErrors{"StatusUpdate": {
+		apierrors.NewServiceUnavailable("busy"),
+		apierrors.NewServiceUnavailable("busy"),
+	}}
+	configured := len(errMap["StatusUpdate"])
+	r := NewReconcilerNullWithConfig(nullScheme(t), ctrl.SetControllerReference, infra.StubConfig{Errors: errMap, Objects: objs})
+	writes := r.k8.TrackWrites()
+
+	var wg sync.WaitGroup
+	unavailable := make(chan error, instances*reconcilesPerInstance)
+	for _, obj := range objs {
+		for i := 0; i < reconcilesPerInstance; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// conflicts between the concurrent reconciles of one instance are expected
+				_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(obj)})
+				if apierrors.IsServiceUnavailable(err) {
+					unavailable <- err
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	close(unavailable)
+
+	for _, obj := range objs {
+		key := client.ObjectKeyFromObject(obj)
+		if _, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("unexpected reconcile error for %s %v", key, err)
+		}
+		if err := r.k8.Get(context.Background(), key, &appsv1.Deployment{}); err != nil {
+			t.Errorf("expected deployment for %s, got %v", key, err)
+		}
+	}
+	if len(unavailable) != configured {
+		t.Errorf("expected each configured error to be returned once, got %d", len(unavailable))
+	}
+	if len(writes.Data()) == 0 {
+		t.Error("expected tracked writes, got none")
+	}
+}
+
+// newNullReconciler returns a nulled reconciler whose object store contains objs.
+func newNullReconciler(t *testing.T, objs ...client.Object) *MemcachedReconciler {
+	t.Helper()
+	return NewReconcilerNullWithConfig(nullScheme(t), ctrl.SetControllerReference, infra.StubConfig{Objects: objs})
+}
+
+func nullScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	s := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(s); err != nil {
+		t.Fatalf("failed to add client-go scheme: %v", err)
+	}
+	if err := cachev1alpha1.AddToScheme(s); err != nil {
+		t.Fatalf("failed to add memcached scheme: %v", err)
+	}
+	return s
+}
+
+// setNullDeploymentStatus simulates the Deployment controller. All replicas
+// are updated and available.
+func setNullDeploymentStatus(t *testing.T, r *MemcachedReconciler, replicas int32) {
+	t.Helper()
+	dep := getNull(t, r, &appsv1.Deployment{})
+	dep.Status = appsv1.DeploymentStatus{
+		ObservedGeneration: dep.Generation,
+		Replicas:           replicas,
+		UpdatedReplicas:    replicas,
+		ReadyReplicas:      replicas,
+		AvailableReplicas:  replicas,
+	}
+	if err := r.k8.StatusUpdate(context.Background(), dep); err != nil {
+		t.Fatalf("unexpected error updating deployment status %v", err)
+	}
+}
+
+// newNullReplicaSet returns a ReplicaSet of the Deployment with the revision.
+func newNullReplicaSet(name string, revision int) *appsv1.ReplicaSet {
+	return &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        nullName.Name + "-" + name,
+			Namespace:   nullName.Namespace,
+			Labels:      labelsForMemcached(nullName.Name),
+			Annotations: map[string]string{revisionAnnotation: fmt.Sprint(revision)},
+		},
+	}
+}
+
+// newNullPod returns a memcached pod of the ReplicaSet.
+func newNullPod(name string, rs *appsv1.ReplicaSet, image string, cs corev1.ContainerStatus) *corev1.Pod {
+	cs.Name = memcachedContainerName
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: nullName.Namespace,
+			Labels:    labelsForMemcached(nullName.Name),
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "apps/v1", Kind: "ReplicaSet", Name: rs.Name, UID: rs.UID, Controller: ptr.To(true),
+			}},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: memcachedContainerName, Image: image}}},
+		Status: corev1.PodStatus{
+			Phase:             corev1.PodRunning,
+			PodIP:             "10.0.0.1",
+			ContainerStatuses: []corev1.ContainerStatus{cs},
+		},
+	}
+}
+
+// runningSince returns the status of a ready container which started at the
+// time.
+func runningSince(startedAt metav1.Time) corev1.ContainerStatus {
+	return corev1.ContainerStatus{
+		Ready:   true,
+		Started: ptr.To(true),
+		State:   corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: startedAt}},
+	}
+}
+
+// restartNullPodGracefully simulates the kubelet restarting the memcached
+// container after the preStop hook stopped it gracefully.
+func restartNullPodGracefully(t *testing.T, r *MemcachedReconciler, name string) {
+	t.Helper()
+	pod := &corev1.Pod{}
+	if err := r.k8.Get(context.Background(), types.NamespacedName{Name: name, Namespace: nullName.Namespace}, pod); err != nil {
+		t.Fatalf("unexpected error getting pod %v", err)
+	}
+	cs := runningSince(metav1.NewTime(r.clock.Now()))
+	cs.Name = memcachedContainerName
+	cs.RestartCount = pod.Status.ContainerStatuses[0].RestartCount + 1
+	cs.LastTerminationState.Terminated = &corev1.ContainerStateTerminated{Reason: "Completed", ExitCode: 0}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{cs}
+	if err := r.k8.StatusUpdate(context.Background(), pod); err != nil {
+		t.Fatalf("unexpected error updating pod status %v", err)
+	}
+}
+
+// expectNullImages expects the memcached images of the pods by pod name.
+func expectNullImages(t *testing.T, r *MemcachedReconciler, expected map[string]string) {
+	t.Helper()
+	pods := &corev1.PodList{}
+	if err := r.k8.List(context.Background(), pods, client.InNamespace(nullName.Namespace)); err != nil {
+		t.Fatalf("unexpected error listing pods %v", err)
+	}
+	images := map[string]string{}
+	for i := range pods.Items {
+		images[pods.Items[i].Name] = containerImage(&pods.Items[i])
+	}
+	if !maps.Equal(images, expected) {
+		t.Errorf("expected images %v, got %v", expected, images)
+	}
+}
+
+func reconcileNull(t *testing.T, r *MemcachedReconciler) {
+	t.Helper()
+	if _, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: nullName}); err != nil {
+		t.Fatalf("unexpected reconcile error %v", err)
+	}
+}
+
+func getNull[T client.Object](t *testing.T, r *MemcachedReconciler, obj T) T {
+	t.Helper()
+	if err := r.k8.Get(context.Background(), nullName, obj); err != nil {
+		t.Fatalf("unexpected error getting %T %v", obj, err)
+	}
+	return obj
+}
+
+func expectNullCondition(
+	t *testing.T,
+	memcached *cachev1alpha1.Memcached,
+	conditionType string,
+	status metav1.ConditionStatus,
+	reason string,
+) {
+	t.Helper()
+	condition := meta.FindStatusCondition(memcached.Status.Conditions, conditionType)
+	if condition == nil {
+		t.Fatalf("expected condition %s, got %v", conditionType, memcached.Status.Conditions)
+	}
+	if condition.Status != status || condition.Reason != reason {
+		t.Errorf("expected %s %s/%s, got %s/%s", conditionType, status, reason, condition.Status, condition.Reason)
+	}
+}
@@ -19,15 +19,22 @@ package controller
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -59,15 +66,41 @@ var _ = Describe("Memcached Controller", func() {
 			expectCondition(metav1.ConditionUnknown, "Reconciling", typeNamespacedName)
 		})
 
-		It("should set resource status to 'True' during second reconciliation loop", func() {
+		It("should set resource status to 'True' once the minimum replicas are available", func() {
 			r := newReconciler()
 
 			By("Reconcile two times")
 			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
 			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
 
-			By("Status 'True' after second reconciliation loop")
-			expectCondition(metav1.ConditionTrue, "Reconciling", typeNamespacedName)
+			By("Status 'False' while no replica is available")
+			expectCondition(metav1.ConditionFalse, reasonMinimumReplicasUnavailable, typeNamespacedName)
+			expectConditionOfType(typeProgressingMemcached, metav1.ConditionTrue, reasonRolloutInProgress, typeNamespacedName)
+
+			By("Status 'True' after the rollout completed")
+			setDeploymentStatus(1, typeNamespacedName)
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+			expectCondition(metav1.ConditionTrue, reasonMinimumReplicasAvailable, typeNamespacedName)
+			expectConditionOfType(typeProgressingMemcached, metav1.ConditionFalse, reasonRolloutComplete, typeNamespacedName)
+			expectConditionOfType(typeDegradedMemcached, metav1.ConditionFalse, reasonAsExpected, typeNamespacedName)
+		})
+
+		It("should set the observed generation on every condition", func() {
+			r := newReconciler()
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+			updateMemcached(typeNamespacedName, func(m *cachev1alpha1.Memcached) {
+				m.Spec.Size = 2
+			})
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+			updated := &cachev1alpha1.Memcached{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.Conditions).To(HaveLen(3))
+			for _, condition := range updated.Status.Conditions {
+				Expect(condition.ObservedGeneration).To(Equal(updated.Generation))
+			}
 		})
 
 		It("should set resource size back to 1 if it was changed", func() {
@@ -77,21 +110,86 @@ var _ = Describe("Memcached Controller", func() {
 			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
 			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
 
-			By("Status 'True' after second reconciliation loop")
-			expectCondition(metav1.ConditionTrue, "Reconciling", typeNamespacedName)
+			By("Status 'False' after second reconciliation loop without available replicas")
+			expectCondition(metav1.ConditionFalse, reasonMinimumReplicasUnavailable, typeNamespacedName)
 
 			By("Manually change deployment size to 2")
 			resizeDeploymentTo(2, typeNamespacedName)
 
-			By("Requeue after size was changed back to 1")
+			By("Stop after size was changed back to 1, the Deployment watch triggers the next reconcile")
+			result, err := reconcileOnce(ctx, r, typeNamespacedName, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(ctrl.Result{}))
+			expectDeploymentSize(1, typeNamespacedName)
+		})
+
+		It("should revert every managed field of a hand-edited deployment", func() {
+			r := newReconciler()
+			events := r.events.TrackEvents()
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+			By("Hand-edit the image, the command and add a label")
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			dep.Spec.Template.Labels["team"] = "cache"
+			dep.Spec.Template.Spec.Containers[0].Image = "memcached:latest"
+			dep.Spec.Template.Spec.Containers[0].Command = []string{"memcached", "-vv"}
+			Expect(k8sClient.Update(ctx, dep)).To(Succeed())
+
 			result, err := reconcileOnce(ctx, r, typeNamespacedName, false)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(result.Requeue).To(BeTrue())
+			Expect(result).To(Equal(ctrl.Result{}))
+
+			container := memcachedContainer(typeNamespacedName)
+			Expect(container.Image).To(Equal(memcachedImage))
+			Expect(container.Command).To(Equal(commandForMemcached(&cachev1alpha1.Memcached{})))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Labels).To(HaveKeyWithValue("team", "cache"))
+			Expect(events.Data()).To(ContainElement(infra.Event{
+				Type:   corev1.EventTypeNormal,
+				Reason: "DriftCorrected",
+				Message: "Reverted spec.template.spec.containers[memcached].image, " +
+					"spec.template.spec.containers[memcached].command of Deployment test-resource",
+			}))
+
+			By("No drift is left after the correction")
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+			expectCondition(metav1.ConditionFalse, reasonMinimumReplicasUnavailable, typeNamespacedName)
+		})
+
+		It("should not revert a hand-edited deployment while paused and correct it after resuming", func() {
+			r := newReconciler()
+
+			By("Reconcile two times")
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+			By("Pause and manually change deployment size to 2")
+			updateMemcached(typeNamespacedName, func(m *cachev1alpha1.Memcached) {
+				m.Annotations = map[string]string{pausedAnnotation: "true"}
+			})
+			resizeDeploymentTo(2, typeNamespacedName)
+
+			result, _ := reconcileOnce(ctx, r, typeNamespacedName, false)
+			Expect(result.Requeue).To(BeFalse())
+			expectDeploymentSize(2, typeNamespacedName)
+			expectConditionOfType(typePausedMemcached, metav1.ConditionTrue, "PausedByAnnotation", typeNamespacedName)
+
+			By("Resume and reconcile once")
+			updateMemcached(typeNamespacedName, func(m *cachev1alpha1.Memcached) {
+				delete(m.Annotations, pausedAnnotation)
+			})
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+			expectDeploymentSize(1, typeNamespacedName)
+			updated := &cachev1alpha1.Memcached{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(meta.FindStatusCondition(updated.Status.Conditions, typePausedMemcached)).To(BeNil())
 		})
 
 		It("should requeue with error if k8 client fails to update deployment replicas size", func() {
 			expectedErr := errors.New("error updating the object")
-			errMap := infra.StubErrors{"Update": {expectedErr}}
+			errMap := infra.StubErrors{"Apply": {nil, expectedErr}}
 
 			r := newReconcilerNull(errMap, true)
 
@@ -106,8 +204,272 @@ var _ = Describe("Memcached Controller", func() {
 			_, err := reconcileOnce(ctx, r, typeNamespacedName, true)
 			Expect(err).To(MatchError(expectedErr))
 
-			By("Status 'False' after second reconciliation loop")
-			expectCondition(metav1.ConditionFalse, "Resizing", typeNamespacedName)
+			By("Status 'Degraded' after second reconciliation loop")
+			expectConditionOfType(typeDegradedMemcached, metav1.ConditionTrue, reasonResizeFailed, typeNamespacedName)
+		})
+
+		It("should emit events when creating the deployment and correcting its size", func() {
+			r := newReconciler()
+			events := r.events.TrackEvents()
+
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+			resizeDeploymentTo(2, typeNamespacedName)
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+			Expect(events.Data()).To(Equal([]infra.Event{{
+				Type:    corev1.EventTypeNormal,
+				Reason:  "Created",
+				Message: "Created Deployment test-resource with 1 replicas",
+			}, {
+				Type:    corev1.EventTypeNormal,
+				Reason:  "DriftCorrected",
+				Message: "Resized Deployment test-resource from 2 back to 1 replicas",
+			}}))
+		})
+
+		It("should emit warning events if resizing and updating the status fail", func() {
+			errMap := infra.StubErrors{
+				"Apply":        {nil, errors.New("error updating the object")},
+				"StatusUpdate": {nil, errors.New("error updating resource status")},
+			}
+			r := newReconcilerNull(errMap, true)
+			events := r.events.TrackEvents()
+
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+			resizeDeploymentTo(2, typeNamespacedName)
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, true)
+
+			Expect(events.Data()).To(ContainElements(
+				infra.Event{
+					Type:    corev1.EventTypeWarning,
+					Reason:  "ResizeFailed",
+					Message: "Failed to resize Deployment test-resource to 1 replicas: error updating the object",
+				},
+				infra.Event{
+					Type:    corev1.EventTypeWarning,
+					Reason:  "StatusUpdateFailed",
+					Message: "Failed to update status: error updating resource status",
+				},
+			))
+		})
+
+		It("should pull the memcached image from the registry mirror using the pull secrets", func() {
+			By("Configure an image pull secret")
+			updateMemcached(typeNamespacedName, func(m *cachev1alpha1.Memcached) {
+				m.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "registry-credentials"}}
+			})
+
+			r := newReconciler().WithRegistryMirrors(RegistryMirrors{"docker.io": "localhost:5000"})
+
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+			By("Deployment uses the mirrored image and the pull secret")
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			podSpec := dep.Spec.Template.Spec
+			Expect(podSpec.Containers[0].Image).To(Equal("localhost:5000/library/memcached:1.6.26-alpine3.19"))
+			Expect(podSpec.ImagePullSecrets).To(ConsistOf(corev1.LocalObjectReference{Name: "registry-credentials"}))
+		})
+
+		It("should set the 'Degraded' condition when a pod can't pull the image", func() {
+			r := newReconciler()
+
+			By("Reconcile two times without pods")
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+			expectConditionOfType(typeDegradedMemcached, metav1.ConditionFalse, reasonAsExpected, typeNamespacedName)
+
+			By("Simulate a pod stuck pulling the image")
+			createPodWith(corev1.ContainerStatus{
+				State: corev1.ContainerState{
+					Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"},
+				},
+			}, typeNamespacedName)
+
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+			expectConditionOfType(typeDegradedMemcached, metav1.ConditionTrue, reasonImagePullFailed, typeNamespacedName)
+		})
+
+		It("should render the default memcached protocol probes", func() {
+			r := newReconciler()
+
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+			container := memcachedContainer(typeNamespacedName)
+			Expect(container.ReadinessProbe.Exec.Command).To(ContainElement(ContainSubstring("printf 'stats")))
+			Expect(container.LivenessProbe.Exec.Command).To(ContainElement(ContainSubstring("printf 'version")))
+			Expect(container.StartupProbe.Exec.Command).To(ContainElement(ContainSubstring("printf 'version")))
+			Expect(container.StartupProbe.FailureThreshold).To(Equal(int32(30)))
+		})
+
+		It("should replace the default probes with the probes from the spec", func() {
+			readiness := &corev1.Probe{
+				ProbeHandler: corev1.ProbeHandler{
+					TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(memcachedPort)},
+				},
+				PeriodSeconds: 5,
+			}
+			updateMemcached(typeNamespacedName, func(m *cachev1alpha1.Memcached) {
+				m.Spec.Probes = &cachev1alpha1.MemcachedProbes{Readiness: readiness}
+			})
+			r := newReconciler()
+
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+			container := memcachedContainer(typeNamespacedName)
+			Expect(container.ReadinessProbe.TCPSocket).NotTo(BeNil())
+			Expect(container.ReadinessProbe.PeriodSeconds).To(Equal(int32(5)))
+			Expect(container.LivenessProbe.Exec).NotTo(BeNil())
+		})
+
+		It("should render a preStop hook draining connections before the graceful shutdown", func() {
+			r := newReconciler()
+
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.TerminationGracePeriodSeconds).To(HaveValue(Equal(int64(30))))
+
+			preStop := dep.Spec.Template.Spec.Containers[0].Lifecycle.PreStop
+			Expect(preStop).NotTo(BeNil())
+			Expect(preStop.Exec.Command).To(Equal([]string{
+				"sh", "-c", "sleep 5; kill -USR1 1; while kill -0 1 2>/dev/null; do sleep 1; done",
+			}))
+		})
+
+		It("should render the termination grace period from the spec", func() {
+			updateMemcached(typeNamespacedName, func(m *cachev1alpha1.Memcached) {
+				m.Spec.TerminationGracePeriodSeconds = ptr.To(int64(120))
+			})
+			r := newReconciler()
+
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.TerminationGracePeriodSeconds).To(HaveValue(Equal(int64(120))))
+		})
+
+		It("should store the cache in a memory file when warm restart is enabled", func() {
+			updateMemcached(typeNamespacedName, func(m *cachev1alpha1.Memcached) {
+				m.Spec.WarmRestart = &cachev1alpha1.WarmRestartSpec{Enabled: true}
+			})
+			r := newReconciler()
+
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			podSpec := dep.Spec.Template.Spec
+			Expect(podSpec.Containers[0].Command).To(ContainElements("-e", memoryFilePath))
+			Expect(podSpec.Containers[0].VolumeMounts).To(ConsistOf(corev1.VolumeMount{
+				Name:      memoryFileVolume,
+				MountPath: memoryFileDir,
+			}))
+			Expect(podSpec.Volumes).To(HaveLen(1))
+			Expect(podSpec.Volumes[0].EmptyDir.Medium).To(Equal(corev1.StorageMediumMemory))
+		})
+
+		It("should record a warm restart after a graceful stop", func() {
+			updateMemcached(typeNamespacedName, func(m *cachev1alpha1.Memcached) {
+				m.Spec.WarmRestart = &cachev1alpha1.WarmRestartSpec{Enabled: true}
+			})
+			r := newReconciler()
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+			By("Simulate a container restarted after a graceful stop")
+			startedAt := metav1.NewTime(time.Now().Truncate(time.Second))
+			createPodWith(corev1.ContainerStatus{
+				Started:      ptr.To(true),
+				Ready:        true,
+				RestartCount: 1,
+				State: corev1.ContainerState{
+					Running: &corev1.ContainerStateRunning{StartedAt: startedAt},
+				},
+				LastTerminationState: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{Reason: "Completed", ExitCode: 0},
+				},
+			}, typeNamespacedName)
+
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+			updated := &cachev1alpha1.Memcached{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.LastRestart).NotTo(BeNil())
+			Expect(updated.Status.LastRestart.Type).To(Equal(cachev1alpha1.RestartTypeWarm))
+			Expect(updated.Status.LastRestart.Pod).To(Equal(typeNamespacedName.Name + "-pod"))
+			Expect(updated.Status.LastRestart.Time.Equal(&startedAt)).To(BeTrue())
+		})
+
+		It("should report a failing readiness probe in the pod status", func() {
+			r := newReconciler()
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+			By("Simulate a running pod which is not ready")
+			createPodWith(corev1.ContainerStatus{
+				Started:      ptr.To(true),
+				Ready:        false,
+				RestartCount: 2,
+				State: corev1.ContainerState{
+					Running: &corev1.ContainerStateRunning{},
+				},
+			}, typeNamespacedName)
+
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+			expectPodStatus(typeNamespacedName, cachev1alpha1.MemcachedPodStatus{
+				Name:         typeNamespacedName.Name + "-pod",
+				Phase:        corev1.PodRunning,
+				Ready:        false,
+				Started:      true,
+				RestartCount: 2,
+				Message:      "readiness probe failing",
+			})
+		})
+
+		It("should roll the pods when spec.restartedAt changes", func() {
+			r := newReconciler()
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+			restartedAt := metav1.NewTime(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+			updateMemcached(typeNamespacedName, func(m *cachev1alpha1.Memcached) {
+				m.Spec.RestartedAt = &restartedAt
+			})
+
+			result, _ := reconcileOnce(ctx, r, typeNamespacedName, false)
+			Expect(result).To(Equal(ctrl.Result{}))
+
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Annotations).To(HaveKeyWithValue(restartedAtAnnotation, "2025-01-02T03:04:05Z"))
+		})
+
+		It("should record the restart when the rollout completed", func() {
+			r := newReconciler()
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+			restartedAt := metav1.NewTime(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+			updateMemcached(typeNamespacedName, func(m *cachev1alpha1.Memcached) {
+				m.Spec.RestartedAt = &restartedAt
+			})
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+			By("Not recording the restart while the rollout is in progress")
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+			updated := &cachev1alpha1.Memcached{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.LastCompletedRestartAt).To(BeNil())
+
+			By("Simulate the Deployment controller completing the rollout")
+			setDeploymentStatus(1, typeNamespacedName)
+
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, updated)).To(Succeed())
+			Expect(updated.Status.LastCompletedRestartAt).NotTo(BeNil())
+			Expect(updated.Status.LastCompletedRestartAt.Equal(&restartedAt)).To(BeTrue())
 		})
 	})
 
@@ -125,29 +487,139 @@ var _ = Describe("Memcached Controller", func() {
 		It("should set resource status to 'False' when setting controller reference for deployment fails", func() {
 			r, errMsg := newReconcilerWithFailingSetter()
 
-			By("Reconcile with error")
+			By("Reconcile with terminal error")
 			_, err := reconcileOnce(ctx, r, typeNamespacedName, true)
-			Expect(err.Error()).To(Equal(errMsg))
+			Expect(err).To(MatchError(ContainSubstring(errMsg)))
+			Expect(errors.Is(err, reconcile.TerminalError(nil))).To(BeTrue())
 
 			By("Status 'False' after first reconciliation loop")
-			expectCondition(metav1.ConditionFalse, "Reconciling", typeNamespacedName)
+			expectCondition(metav1.ConditionFalse, reasonReconcileFailed, typeNamespacedName)
+			expectConditionOfType(typeDegradedMemcached, metav1.ConditionTrue, reasonReconcileFailed, typeNamespacedName)
 		})
 
-		It("should requeue with error if k8 client fails to get the resource although it exists", func() {
-			expectedErr := errors.New("error reading the object")
-			errMap := infra.StubErrors{"Get": {expectedErr}}
-			r := newReconcilerNull(errMap, false)
+		It("should stop with a terminal error if applying the deployment is forbidden", func() {
+			forbidden := apierrors.NewForbidden(appsv1.Resource("deployments"), typeNamespacedName.Name, errors.New("no RBAC"))
+			r := newReconcilerNull(infra.StubErrors{"Apply": {forbidden}}, true)
+			events := r.events.TrackEvents()
 
 			_, err := reconcileOnce(ctx, r, typeNamespacedName, true)
-			Expect(err).To(MatchError(expectedErr))
+			Expect(err).To(MatchError(forbidden))
+			Expect(errors.Is(err, reconcile.TerminalError(nil))).To(BeTrue())
 
 			expectNoDeployment(typeNamespacedName)
+			expectConditionOfType(typeDegradedMemcached, metav1.ConditionTrue, reasonForbidden, typeNamespacedName)
+			Expect(events.Data()).To(ContainElement(infra.Event{
+				Type:    corev1.EventTypeWarning,
+				Reason:  reasonForbidden,
+				Message: "Stopped reconciling: " + forbidden.Error(),
+			}))
 		})
 
-		It("should requeue with error if k8 client fails to update memcached resource status", func() {
-			expectedErr := errors.New("error updating resource status")
-			errMap := infra.StubErrors{"StatusUpdate": {expectedErr}}
-			r := newReconcilerNull(errMap, false)
+		It("should stop with a terminal error if the deployment is controlled by someone else", func() {
+			dep := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespacedName.Name,
+					Namespace: typeNamespacedName.Namespace,
+					OwnerReferences: []metav1.OwnerReference{{
+						APIVersion: "v1",
+						Kind:       "ConfigMap",
+						Name:       "other-owner",
+						UID:        "other-owner-uid",
+						Controller: ptr.To(true),
+					}},
+				},
+				Spec: appsv1.DeploymentSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: labelsForMemcached(typeNamespacedName.Name)},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: labelsForMemcached(typeNamespacedName.Name)},
+						Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "other", Image: "other"}}},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, dep)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, dep)).To(Succeed()) })
+
+			_, err := reconcileOnce(ctx, newReconciler(), typeNamespacedName, true)
+			Expect(errors.Is(err, reconcile.TerminalError(nil))).To(BeTrue())
+
+			expectConditionOfType(typeDegradedMemcached, metav1.ConditionTrue, reasonOwnershipConflict, typeNamespacedName)
+			Expect(memcachedContainer(typeNamespacedName).Image).To(Equal("other"))
+		})
+
+		It("should write the status, the finalizer and the deployment in order without an API server", func() {
+			r := newReconcilerNull(nil, false, newMemcached(typeNamespacedName))
+			writes := r.k8.TrackWrites()
+
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+			Expect(writeActions(writes)).To(Equal([]string{
+				"StatusUpdate *v1alpha1.Memcached",
+				"Patch *v1alpha1.Memcached",
+				"Apply *v1.Deployment",
+			}))
+			data := writes.Data()
+			Expect(meta.FindStatusCondition(data[0].Object.(*cachev1alpha1.Memcached).Status.Conditions,
+				typeAvailableMemcached).Reason).To(Equal(reasonReconciling))
+			Expect(data[1].Object.GetFinalizers()).To(ConsistOf(memcachedFinalizer))
+			Expect(data[2].Object.(*appsv1.Deployment).Spec.Template.Spec.Containers[0].Image).To(Equal(memcachedImage))
+		})
+
+		It("should resize a deployment found with 2 replicas without an API server", func() {
+			found := &cachev1alpha1.Memcached{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       typeNamespacedName.Name,
+					Namespace:  typeNamespacedName.Namespace,
+					UID:        "memcached-uid",
+					Finalizers: []string{memcachedFinalizer},
+				},
+				Spec: cachev1alpha1.MemcachedSpec{Size: 1},
+			}
+			setUnknownConditions(found)
+			dep, err := newReconciler().deploymentForMemcached(found)
+			Expect(err).NotTo(HaveOccurred())
+			dep.Spec.Replicas = ptr.To(int32(2))
+
+			r := newReconcilerWithResponses(infra.StubResponses{
+				{Method: "Get", GVK: cachev1alpha1.GroupVersion.WithKind("Memcached"), Key: typeNamespacedName}: {
+					{Object: found},
+					{Object: found},
+				},
+				{Method: "Get", GVK: appsv1.SchemeGroupVersion.WithKind("Deployment"), Key: typeNamespacedName}: {
+					{Err: apierrors.NewServiceUnavailable("etcd leader changed")},
+					{Object: dep},
+				},
+			})
+			writes, events := r.k8.TrackWrites(), r.events.TrackEvents()
+
+			By("Requeue with the error of the first response")
+			_, err = reconcileOnce(ctx, r, typeNamespacedName, true)
+			Expect(apierrors.IsServiceUnavailable(err)).To(BeTrue())
+
+			By("Resize the deployment of the second response")
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+			Expect(writeActions(writes)).To(Equal([]string{"Apply *v1.Deployment"}))
+			Expect(writes.Data()[0].Object.(*appsv1.Deployment).Spec.Replicas).To(HaveValue(Equal(int32(1))))
+			Expect(events.Data()).To(ContainElement(infra.Event{
+				Type:    corev1.EventTypeNormal,
+				Reason:  "DriftCorrected",
+				Message: "Resized Deployment test-resource from 2 back to 1 replicas",
+			}))
+		})
+
+		It("should not write the deployment when the status update fails", func() {
+			errMap := infra.StubErrors{"StatusUpdate": {errors.New("error updating resource status")}}
+			r := newReconcilerNull(errMap, false, newMemcached(typeNamespacedName))
+			writes := r.k8.TrackWrites()
+
+			_, _ = reconcileOnce(ctx, r, typeNamespacedName, true)
+
+			Expect(writes.Data()).To(BeEmpty())
+		})
+
+		It("should requeue with error if k8 client fails to get the resource although it exists", func() {
+			expectedErr := errors.New("error reading the object")
+			errMap := infra.StubErrors{"Get": {expectedErr}}
+			r := newReconcilerNull(errMap, false, newMemcached(typeNamespacedName))
 
 			_, err := reconcileOnce(ctx, r, typeNamespacedName, true)
 			Expect(err).To(MatchError(expectedErr))
@@ -155,10 +627,10 @@ var _ = Describe("Memcached Controller", func() {
 			expectNoDeployment(typeNamespacedName)
 		})
 
-		It("should requeue with error if k8 client fails to get the memcached resource after status update", func() {
-			expectedErr := errors.New("error reading the object")
-			errMap := infra.StubErrors{"Get": {nil, expectedErr}}
-			r := newReconcilerNull(errMap, false)
+		It("should requeue with error if k8 client fails to update memcached resource status", func() {
+			expectedErr := errors.New("error updating resource status")
+			errMap := infra.StubErrors{"StatusUpdate": {expectedErr}}
+			r := newReconcilerNull(errMap, false, newMemcached(typeNamespacedName))
 
 			_, err := reconcileOnce(ctx, r, typeNamespacedName, true)
 			Expect(err).To(MatchError(expectedErr))
@@ -169,10 +641,10 @@ var _ = Describe("Memcached Controller", func() {
 		It("should requeue with error if k8 client fails to create deployment", func() {
 			expectedErr := errors.New("error reading the object")
 			errMap := infra.StubErrors{
-				"Get":    {nil, nil, apierrors.NewNotFound(schema.GroupResource{}, "deployment not found")},
-				"Create": {expectedErr},
+				"Get":   {nil, apierrors.NewNotFound(schema.GroupResource{}, "deployment not found")},
+				"Apply": {expectedErr},
 			}
-			r := newReconcilerNull(errMap, false)
+			r := newReconcilerNull(errMap, false, newMemcached(typeNamespacedName))
 
 			_, err := reconcileOnce(ctx, r, typeNamespacedName, true)
 			Expect(err).To(MatchError(expectedErr))
@@ -183,9 +655,9 @@ var _ = Describe("Memcached Controller", func() {
 		It("should requeue with error if k8 client fails to get the deployment for other reasons than 'Not Found'", func() {
 			expectedErr := apierrors.NewTimeoutError("getting the deployment timed out", 1)
 			errMap := infra.StubErrors{
-				"Get": {nil, nil, expectedErr},
+				"Get": {nil, expectedErr},
 			}
-			r := newReconcilerNull(errMap, false)
+			r := newReconcilerNull(errMap, false, newMemcached(typeNamespacedName))
 
 			_, err := reconcileOnce(ctx, r, typeNamespacedName, true)
 			Expect(err).To(MatchError(expectedErr))
@@ -249,6 +721,7 @@ func cleanUp(typeNamespacedName types.NamespacedName, withDeployment bool) {
 
 	By("Cleanup the specific resource instance Memcached")
 	Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+	removeFinalizer(typeNamespacedName)
 
 	if !withDeployment {
 		return
@@ -262,16 +735,46 @@ func cleanUp(typeNamespacedName types.NamespacedName, withDeployment bool) {
 	Expect(k8sClient.Delete(ctx, dep)).To(Succeed())
 }
 
+// removeFinalizer releases the finalizer of a deleted Memcached. There is no
+// controller running in the test cluster which would do it.
+func removeFinalizer(t types.NamespacedName) {
+	memcached := &cachev1alpha1.Memcached{}
+	if err := k8sClient.Get(ctx, t, memcached); apierrors.IsNotFound(err) {
+		return
+	}
+	if controllerutil.RemoveFinalizer(memcached, memcachedFinalizer) {
+		Expect(k8sClient.Update(ctx, memcached)).To(Succeed())
+	}
+}
+
 func newReconciler() *MemcachedReconciler {
-	return NewReconciler(k8sClient.Scheme(), k8sClient, ctrl.SetControllerReference)
+	return NewReconciler(k8sClient.Scheme(), k8sClient, &record.FakeRecorder{}, ctrl.SetControllerReference)
 }
 
-func newReconcilerNull(errMap infra.StubErrors, withRealK8 bool) *MemcachedReconciler {
+// newReconcilerNull returns a reconciler with the Embedded Stub. Without the
+// real k8 client the stub's in-memory object store contains objs.
+func newReconcilerNull(errMap infra.StubErrors, withRealK8 bool, objs ...client.Object) *MemcachedReconciler {
 	var k8 client.Client
 	if withRealK8 {
 		k8 = k8sClient
 	}
-	return NewReconcilerNull(k8sClient.Scheme(), k8, ctrl.SetControllerReference, errMap)
+	return NewReconcilerNullWithConfig(k8sClient.Scheme(), ctrl.SetControllerReference, infra.StubConfig{
+		Errors:  errMap,
+		K8:      k8,
+		Objects: objs,
+	})
+}
+
+// newMemcached returns a Memcached of size 1 which isn't created anywhere yet.
+func newMemcached(t types.NamespacedName) *cachev1alpha1.Memcached {
+	return &cachev1alpha1.Memcached{
+		ObjectMeta: metav1.ObjectMeta{Name: t.Name, Namespace: t.Namespace, UID: types.UID(t.Name + "-uid")},
+		Spec:       cachev1alpha1.MemcachedSpec{Size: 1},
+	}
+}
+
+func newReconcilerWithResponses(responses infra.StubResponses) *MemcachedReconciler {
+	return NewReconcilerNullWithConfig(k8sClient.Scheme(), ctrl.SetControllerReference, infra.StubConfig{Responses: responses})
 }
 
 func newReconcilerWithFailingSetter() (*MemcachedReconciler, string) {
@@ -280,7 +783,7 @@ func newReconcilerWithFailingSetter() (*MemcachedReconciler, string) {
 		return errors.New(errMsg)
 	}
 
-	return NewReconciler(k8sClient.Scheme(), k8sClient, ownerRefFor), errMsg
+	return NewReconciler(k8sClient.Scheme(), k8sClient, &record.FakeRecorder{}, ownerRefFor), errMsg
 }
 
 func reconcileOnce(c context.Context, r *MemcachedReconciler, t types.NamespacedName, expectFail bool) (ctrl.Result, error) {
@@ -297,6 +800,15 @@ func reconcileOnce(c context.Context, r *MemcachedReconciler, t types.Namespaced
 	return result, err
 }
 
+// writeActions returns the tracked writes as "<action> <type of object>".
+func writeActions(tracker *infra.WriteTracker) []string {
+	var actions []string
+	for _, write := range tracker.Data() {
+		actions = append(actions, fmt.Sprintf("%s %T", write.Action, write.Object))
+	}
+	return actions
+}
+
 func expectNoDeployment(t types.NamespacedName) {
 	By("No deployment was created")
 	err := k8sClient.Get(ctx, t, &appsv1.Deployment{})
@@ -321,9 +833,82 @@ func resizeDeploymentTo(size int32, t types.NamespacedName) {
 	Expect(*dep.Spec.Replicas).To(Equal(int32(2)))
 }
 
-func expectCondition(status metav1.ConditionStatus, reason string, t types.NamespacedName) {
+// setDeploymentStatus simulates the Deployment controller which doesn't run in
+// the test cluster. All replicas are updated and available.
+func setDeploymentStatus(replicas int32, t types.NamespacedName) {
+	dep := &appsv1.Deployment{}
+	Expect(k8sClient.Get(ctx, t, dep)).To(Succeed())
+	dep.Status = appsv1.DeploymentStatus{
+		ObservedGeneration: dep.Generation,
+		Replicas:           replicas,
+		UpdatedReplicas:    replicas,
+		ReadyReplicas:      replicas,
+		AvailableReplicas:  replicas,
+	}
+	Expect(k8sClient.Status().Update(ctx, dep)).To(Succeed())
+}
+
+func expectDeploymentSize(size int32, t types.NamespacedName) {
+	dep := &appsv1.Deployment{}
+	Expect(k8sClient.Get(ctx, t, dep)).To(Succeed())
+	Expect(dep.Spec.Replicas).To(HaveValue(Equal(size)))
+}
+
+func expectConditionOfType(conditionType string, status metav1.ConditionStatus, reason string, t types.NamespacedName) {
 	updated := &cachev1alpha1.Memcached{}
 	Expect(k8sClient.Get(ctx, t, updated)).To(Succeed())
-	Expect(updated.Status.Conditions[0].Status).To(Equal(status))
-	Expect(updated.Status.Conditions[0].Reason).To(Equal(reason))
+	condition := meta.FindStatusCondition(updated.Status.Conditions, conditionType)
+	Expect(condition).NotTo(BeNil())
+	Expect(condition.Status).To(Equal(status))
+	Expect(condition.Reason).To(Equal(reason))
+}
+
+func updateMemcached(t types.NamespacedName, mutate func(*cachev1alpha1.Memcached)) {
+	memcached := &cachev1alpha1.Memcached{}
+	Expect(k8sClient.Get(ctx, t, memcached)).To(Succeed())
+	mutate(memcached)
+	Expect(k8sClient.Update(ctx, memcached)).To(Succeed())
+}
+
+// createPodWith creates a pod of the Memcached resource with the given status
+// of the memcached container. There is no kubelet in the test environment so the
+// status is set manually and the pod is removed without grace period.
+func createPodWith(containerStatus corev1.ContainerStatus, t types.NamespacedName) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      t.Name + "-pod",
+			Namespace: t.Namespace,
+			Labels:    labelsForMemcached(t.Name),
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: memcachedContainerName, Image: memcachedImage}},
+		},
+	}
+	Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+	DeferCleanup(func() {
+		Expect(k8sClient.Delete(ctx, pod, client.GracePeriodSeconds(0))).To(Succeed())
+	})
+
+	containerStatus.Name = memcachedContainerName
+	containerStatus.Image = memcachedImage
+	pod.Status.Phase = corev1.PodRunning
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{containerStatus}
+	Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+}
+
+func memcachedContainer(t types.NamespacedName) corev1.Container {
+	dep := &appsv1.Deployment{}
+	Expect(k8sClient.Get(ctx, t, dep)).To(Succeed())
+	Expect(dep.Spec.Template.Spec.Containers).To(HaveLen(1))
+	return dep.Spec.Template.Spec.Containers[0]
+}
+
+func expectPodStatus(t types.NamespacedName, expected cachev1alpha1.MemcachedPodStatus) {
+	updated := &cachev1alpha1.Memcached{}
+	Expect(k8sClient.Get(ctx, t, updated)).To(Succeed())
+	Expect(updated.Status.Pods).To(ConsistOf(expected))
+}
+
+func expectCondition(status metav1.ConditionStatus, reason string, t types.NamespacedName) {
+	expectConditionOfType(typeAvailableMemcached, status, reason, t)
 }
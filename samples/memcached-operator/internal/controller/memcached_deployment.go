@@ -0,0 +1,222 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cachev1alpha1 "example.com/m/v2/api/v1alpha1"
+)
+
+// fieldOwner is the field manager of every resource the operator applies.
+const fieldOwner = client.FieldOwner("memcached-operator")
+
+// restartedAtField is the drifted field of a requested rolling restart which is
+// reported as restart instead of drift.
+var restartedAtField = fmt.Sprintf("spec.template.metadata.annotations[%s]", restartedAtAnnotation)
+
+// apply applies the child resource with server-side apply. The operator owns
+// only the fields it sets, fields set by other managers are kept. Conflicting
+// fields, e.g. a hand-edited image, are taken over.
+func (r *MemcachedReconciler) apply(ctx context.Context, obj client.Object) error {
+	return r.k8.Apply(ctx, obj, fieldOwner, client.ForceOwnership)
+}
+
+// reconcileDeployment applies the desired Deployment and returns the live one
+// and whether the Deployment was created or changed.
+func (r *MemcachedReconciler) reconcileDeployment(
+	ctx context.Context,
+	memcached *cachev1alpha1.Memcached,
+) (*appsv1.Deployment, bool, error) {
+	log := logf.FromContext(ctx)
+
+	live := &appsv1.Deployment{}
+	err := r.k8.Get(ctx, types.NamespacedName{Name: memcached.Name, Namespace: memcached.Namespace}, live)
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Error(err, "Failed to get Deployment")
+		return nil, false, err
+	}
+	exists := err == nil
+
+	// A Deployment of the same name controlled by someone else is never taken
+	// over, the apply would be rejected for a second controller reference anyway
+	if owner := metav1.GetControllerOf(live); exists && owner != nil && owner.UID != memcached.UID {
+		log.Info("Deployment is controlled by another owner", "owner", owner.Name, "kind", owner.Kind)
+		return nil, false, &controllerutil.AlreadyOwnedError{Object: live, Owner: *owner}
+	}
+
+	dep, err := r.deploymentForMemcached(memcached)
+	if err != nil {
+		log.Error(err, "Failed to define new Deployment resource for Memcached")
+
+		// The Deployment is defined from the Memcached alone, retrying fails the
+		// same way until the Memcached changes. The caller reports the error as
+		// Degraded condition and stops.
+		err = fmt.Errorf("failed to create Deployment for the custom resource (%s): %w", memcached.Name, err)
+		setCondition(memcached, typeAvailableMemcached, metav1.ConditionFalse, reasonReconcileFailed, err.Error())
+
+		return nil, false, reconcile.TerminalError(err)
+	}
+
+	if !exists {
+		return r.createDeployment(ctx, memcached, dep)
+	}
+
+	return r.applyDeployment(ctx, memcached, dep, live)
+}
+
+func (r *MemcachedReconciler) createDeployment(
+	ctx context.Context,
+	memcached *cachev1alpha1.Memcached,
+	dep *appsv1.Deployment,
+) (*appsv1.Deployment, bool, error) {
+	log := logf.FromContext(ctx)
+
+	log.Info("Creating a new Deployment",
+		"Deployment.Namespace", dep.Namespace, "Deployment.Name", dep.Name)
+	if err := r.apply(ctx, dep); err != nil {
+		log.Error(err, "Failed to create new Deployment",
+			"Deployment.Namespace", dep.Namespace, "Deployment.Name", dep.Name)
+		r.events.Eventf(memcached, corev1.EventTypeWarning, "CreateFailed",
+			"Failed to create Deployment %s: %s", dep.Name, err)
+
+		return nil, false, err
+	}
+	r.events.Eventf(memcached, corev1.EventTypeNormal, "Created",
+		"Created Deployment %s with %d replicas", dep.Name, memcached.Spec.Size)
+
+	return dep, true, nil
+}
+
+// applyDeployment applies the desired Deployment on every reconciliation. It
+// resizes the Deployment to spec.size, rolls the pods when spec.restartedAt
+// changed and reverts every other managed field which drifted, e.g. an image
+// changed by hand. Fields the operator stopped managing are removed. With warm
+// restart a new image is updated in place instead of rolling the pods.
+func (r *MemcachedReconciler) applyDeployment(
+	ctx context.Context,
+	memcached *cachev1alpha1.Memcached,
+	dep, live *appsv1.Deployment,
+) (*appsv1.Deployment, bool, error) {
+	log := logf.FromContext(ctx).WithValues("Deployment.Namespace", live.Namespace, "Deployment.Name", live.Name)
+
+	keepLiveSelector(dep, live)
+	size, liveSize := memcached.Spec.Size, ptr.Deref(live.Spec.Replicas, 1)
+	resize := liveSize != size
+	restart := restartRequested(memcached, live)
+	drifted := slices.DeleteFunc(driftedFields(dep, live), func(field string) bool {
+		return field == restartedAtField
+	})
+	if updatesInPlace(memcached, live, resize, restart, drifted) {
+		return r.updateInPlace(ctx, memcached, dep, live)
+	}
+
+	switch {
+	case resize:
+		log.Info(fmt.Sprintf("found diverging size (%d), changing back to (%d)", liveSize, size))
+	case restart:
+		log.Info("rolling restart requested", "restartedAt", restartedAtFor(memcached))
+	case len(drifted) > 0:
+		log.Info("found drift, reverting fields", "fields", drifted)
+	default:
+		log.Info("all good, no drift in the deployment found")
+	}
+
+	if err := r.apply(ctx, dep); err != nil {
+		log.Error(err, "Failed to apply Deployment")
+		r.applyFailed(ctx, memcached, live, resize, restart, drifted, err)
+		return nil, false, err
+	}
+
+	if resize {
+		r.events.Eventf(memcached, corev1.EventTypeNormal, "DriftCorrected",
+			"Resized Deployment %s from %d back to %d replicas", live.Name, liveSize, size)
+	}
+	if restart {
+		r.events.Eventf(memcached, corev1.EventTypeNormal, "RollingRestart",
+			"Rolling the pods of Deployment %s restarted at %s", live.Name, restartedAtFor(memcached))
+	}
+	if len(drifted) > 0 {
+		r.events.Eventf(memcached, corev1.EventTypeNormal, "DriftCorrected",
+			"Reverted %s of Deployment %s", strings.Join(drifted, ", "), live.Name)
+	}
+
+	return dep, resize || restart || len(drifted) > 0, nil
+}
+
+// keepLiveSelector keeps the selector of the live Deployment, spec.selector is
+// immutable and applying another one is rejected as invalid. Deployments created
+// before the instance label was added select their pods by the name label only.
+// They keep that selector, their pods get the instance label with the rollout of
+// the changed template. A selector which doesn't select the desired pods is
+// applied as desired and rejected.
+func keepLiveSelector(desired, live *appsv1.Deployment) {
+	if live.Spec.Selector == nil {
+		return
+	}
+	selector, err := metav1.LabelSelectorAsSelector(live.Spec.Selector)
+	if err != nil || !selector.Matches(labels.Set(desired.Spec.Template.Labels)) {
+		return
+	}
+	desired.Spec.Selector = live.Spec.Selector.DeepCopy()
+}
+
+// applyFailed reports why the Deployment couldn't be applied. A failed resize
+// is reported in the status as well.
+func (r *MemcachedReconciler) applyFailed(
+	ctx context.Context,
+	memcached *cachev1alpha1.Memcached,
+	live *appsv1.Deployment,
+	resize, restart bool,
+	drifted []string,
+	err error,
+) {
+	if restart {
+		r.events.Eventf(memcached, corev1.EventTypeWarning, "RestartFailed",
+			"Failed to restart Deployment %s: %s", live.Name, err)
+	}
+	if len(drifted) > 0 {
+		r.events.Eventf(memcached, corev1.EventTypeWarning, "DriftCorrectionFailed",
+			"Failed to revert %s of Deployment %s: %s", strings.Join(drifted, ", "), live.Name, err)
+	}
+	if !resize {
+		return
+	}
+
+	r.events.Eventf(memcached, corev1.EventTypeWarning, "ResizeFailed",
+		"Failed to resize Deployment %s to %d replicas: %s", live.Name, memcached.Spec.Size, err)
+	setCondition(memcached, typeDegradedMemcached, metav1.ConditionTrue, reasonResizeFailed,
+		fmt.Sprintf("Failed to update the size for the custom resource (%s): (%s)", memcached.Name, err))
+	// The status update error is logged and recorded as event, the apply error
+	// is returned either way
+	_ = r.updateStatus(ctx, memcached)
+}
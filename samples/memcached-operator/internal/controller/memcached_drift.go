@@ -0,0 +1,118 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/conversion"
+)
+
+// desiredStateEquality compares the desired with the live state semantically.
+// Used with DeepDerivative empty strings, slices, maps and nil pointers of the
+// desired state are unset. On top of that zero numbers are unset, e.g. the
+// success threshold of a probe which the server defaults to 1.
+var desiredStateEquality = func() conversion.Equalities {
+	e := equality.Semantic.Copy()
+	if err := e.AddFuncs(
+		func(desired, live int32) bool { return desired == 0 || desired == live },
+		func(desired, live int64) bool { return desired == 0 || desired == live },
+	); err != nil {
+		panic(err)
+	}
+	return e
+}()
+
+// driftedFields returns the paths of the managed fields of the live Deployment
+// which drifted from the desired Deployment. A field is managed if it is set in
+// the desired Deployment. Labels and annotations added by others and containers
+// other than memcached are not managed. The replicas are compared on their own.
+func driftedFields(desired, live *appsv1.Deployment) []string {
+	var drifted []string
+
+	template := "spec.template"
+	drifted = append(drifted, driftedKeys(template+".metadata.labels",
+		desired.Spec.Template.Labels, live.Spec.Template.Labels)...)
+	drifted = append(drifted, driftedKeys(template+".metadata.annotations",
+		desired.Spec.Template.Annotations, live.Spec.Template.Annotations)...)
+	drifted = append(drifted, driftedStructFields(template+".spec",
+		&desired.Spec.Template.Spec, &live.Spec.Template.Spec, "containers")...)
+
+	for _, container := range desired.Spec.Template.Spec.Containers {
+		path := fmt.Sprintf("%s.spec.containers[%s]", template, container.Name)
+		found := containerNamed(live.Spec.Template.Spec.Containers, container.Name)
+		if found == nil {
+			drifted = append(drifted, path)
+			continue
+		}
+		drifted = append(drifted, driftedStructFields(path, &container, found)...)
+	}
+
+	return drifted
+}
+
+// driftedKeys returns the keys of desired which are missing or differ in live.
+func driftedKeys(path string, desired, live map[string]string) []string {
+	var drifted []string
+	for key, value := range desired {
+		if current, ok := live[key]; ok && current == value {
+			continue
+		}
+		drifted = append(drifted, fmt.Sprintf("%s[%s]", path, key))
+	}
+	sort.Strings(drifted)
+
+	return drifted
+}
+
+// driftedStructFields compares the fields of the structs desired and live point
+// to. The fields are named after their json tag, skipped fields are ignored.
+func driftedStructFields(path string, desired, live any, skip ...string) []string {
+	desiredValue := reflect.ValueOf(desired).Elem()
+	liveValue := reflect.ValueOf(live).Elem()
+
+	var drifted []string
+	for i := range desiredValue.NumField() {
+		name := strings.Split(desiredValue.Type().Field(i).Tag.Get("json"), ",")[0]
+		if slices.Contains(skip, name) {
+			continue
+		}
+
+		if !desiredStateEquality.DeepDerivative(desiredValue.Field(i).Interface(), liveValue.Field(i).Interface()) {
+			drifted = append(drifted, path+"."+name)
+		}
+	}
+
+	return drifted
+}
+
+func containerNamed(containers []corev1.Container, name string) *corev1.Container {
+	for i := range containers {
+		if containers[i].Name == name {
+			return &containers[i]
+		}
+	}
+
+	return nil
+}
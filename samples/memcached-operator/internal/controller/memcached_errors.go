@@ -0,0 +1,93 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cachev1alpha1 "example.com/m/v2/api/v1alpha1"
+)
+
+// Reasons of the Degraded condition for terminal errors.
+const (
+	reasonInvalidSpec        = "InvalidSpec"
+	reasonOwnershipConflict  = "OwnershipConflict"
+	reasonForbidden          = "Forbidden"
+	reasonUnsupportedRequest = "UnsupportedRequest"
+)
+
+// terminalReason returns the reason of the Degraded condition and true if the
+// error is terminal. Retrying a terminal error fails the same way until the
+// Memcached, the RBAC rules or the owner of the Deployment change. All other
+// errors, e.g. conflicts, timeouts or an unavailable API server, are transient.
+func terminalReason(err error) (string, bool) {
+	var alreadyOwned *controllerutil.AlreadyOwnedError
+
+	switch {
+	case err == nil:
+		return "", false
+	case errors.As(err, &alreadyOwned):
+		return reasonOwnershipConflict, true
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err), apierrors.IsRequestEntityTooLargeError(err):
+		return reasonInvalidSpec, true
+	case apierrors.IsForbidden(err):
+		return reasonForbidden, true
+	case apierrors.IsMethodNotSupported(err), apierrors.IsNotAcceptable(err), apierrors.IsUnsupportedMediaType(err):
+		return reasonUnsupportedRequest, true
+	case errors.Is(err, reconcile.TerminalError(nil)):
+		return reasonReconcileFailed, true
+	default:
+		return "", false
+	}
+}
+
+// fail requeues the request with backoff if the error is transient. A terminal
+// error is reported with the Degraded condition and stops the reconciliation
+// until the Memcached changes, a fix of the RBAC rules or of the owner of the
+// Deployment triggers no reconciliation. The memcached is nil if it couldn't be
+// read.
+func (r *MemcachedReconciler) fail(
+	ctx context.Context,
+	memcached *cachev1alpha1.Memcached,
+	err error,
+) (ctrl.Result, error) {
+	reason, terminal := terminalReason(err)
+	if !terminal {
+		return requeueWith(err)
+	}
+
+	if memcached != nil {
+		r.events.Eventf(memcached, corev1.EventTypeWarning, reason, "Stopped reconciling: %s", err)
+		setCondition(memcached, typeDegradedMemcached, metav1.ConditionTrue, reason, err.Error())
+		// The status update error is logged and recorded as event, the terminal
+		// error is returned either way
+		_ = r.updateStatus(ctx, memcached)
+	}
+
+	if errors.Is(err, reconcile.TerminalError(nil)) {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, reconcile.TerminalError(err)
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("Memcached Error Classification", func() {
+	deployments := appsv1.Resource("deployments")
+	deploymentKind := schema.GroupKind{Group: appsv1.GroupName, Kind: "Deployment"}
+	cause := errors.New("cause")
+
+	DescribeTable("should classify",
+		func(err error, expectedReason string, expectedTerminal bool) {
+			reason, terminal := terminalReason(err)
+			Expect(terminal).To(Equal(expectedTerminal))
+			Expect(reason).To(Equal(expectedReason))
+		},
+		// terminal
+		Entry("invalid", apierrors.NewInvalid(deploymentKind, "memcached", field.ErrorList{
+			field.Invalid(field.NewPath("spec", "replicas"), -1, "must be greater than or equal to 0"),
+		}), reasonInvalidSpec, true),
+		Entry("bad request", apierrors.NewBadRequest("bad"), reasonInvalidSpec, true),
+		Entry("request entity too large", apierrors.NewRequestEntityTooLargeError("too large"), reasonInvalidSpec, true),
+		Entry("forbidden", apierrors.NewForbidden(deployments, "memcached", cause), reasonForbidden, true),
+		Entry("method not supported", apierrors.NewMethodNotSupported(deployments, "patch"), reasonUnsupportedRequest, true),
+		Entry("ownership conflict", &controllerutil.AlreadyOwnedError{
+			Object: &metav1.ObjectMeta{Name: "memcached"},
+			Owner:  metav1.OwnerReference{Kind: "ConfigMap", Name: "other"},
+		}, reasonOwnershipConflict, true),
+		Entry("terminal", reconcile.TerminalError(cause), reasonReconcileFailed, true),
+		Entry("wrapped forbidden", fmt.Errorf("apply: %w", apierrors.NewForbidden(deployments, "memcached", cause)),
+			reasonForbidden, true),
+		// transient
+		Entry("conflict", apierrors.NewConflict(deployments, "memcached", cause), "", false),
+		Entry("not found", apierrors.NewNotFound(deployments, "memcached"), "", false),
+		Entry("already exists", apierrors.NewAlreadyExists(deployments, "memcached"), "", false),
+		Entry("unauthorized", apierrors.NewUnauthorized("token expired"), "", false),
+		Entry("timeout", apierrors.NewTimeoutError("timeout", 1), "", false),
+		Entry("server timeout", apierrors.NewServerTimeout(deployments, "patch", 1), "", false),
+		Entry("too many requests", apierrors.NewTooManyRequests("slow down", 1), "", false),
+		Entry("service unavailable", apierrors.NewServiceUnavailable("unavailable"), "", false),
+		Entry("internal error", apierrors.NewInternalError(cause), "", false),
+		Entry("resource expired", apierrors.NewResourceExpired("expired"), "", false),
+		Entry("unknown", cause, "", false),
+		Entry("no error", nil, "", false),
+	)
+})
@@ -0,0 +1,168 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	cachev1alpha1 "example.com/m/v2/api/v1alpha1"
+)
+
+const (
+	// memcachedFinalizer keeps a deleted Memcached until its cleanup ran.
+	memcachedFinalizer = "cache.example.com/finalizer"
+
+	typeCleanupFailedMemcached = "CleanupFailed"
+
+	// cleanupTimeout is the time after the deletion when the finalizer is
+	// released even if the cleanup keeps failing.
+	cleanupTimeout = 5 * time.Minute
+
+	// claimAnnotation marks a PersistentVolumeClaim created for a Memcached, e.g.
+	// by the tooling provisioning its storage. The value is the Memcached name.
+	claimAnnotation = "cache.example.com/memcached"
+)
+
+// addFinalizer adds the finalizer with a patch so that it doesn't conflict with
+// concurrent changes of the Memcached.
+func (r *MemcachedReconciler) addFinalizer(ctx context.Context, memcached *cachev1alpha1.Memcached) error {
+	if controllerutil.ContainsFinalizer(memcached, memcachedFinalizer) {
+		return nil
+	}
+
+	patch := client.MergeFrom(memcached.DeepCopy())
+	controllerutil.AddFinalizer(memcached, memcachedFinalizer)
+	return r.k8.Patch(ctx, memcached, patch)
+}
+
+// reconcileDelete runs the cleanup of a deleted Memcached and releases the
+// finalizer. A failed cleanup is reported with the 'CleanupFailed' condition and
+// retried until the cleanupTimeout expired, the finalizer is released then
+// anyway so that the deletion doesn't block forever.
+func (r *MemcachedReconciler) reconcileDelete(
+	ctx context.Context,
+	memcached *cachev1alpha1.Memcached,
+) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	if !controllerutil.ContainsFinalizer(memcached, memcachedFinalizer) {
+		return stop()
+	}
+
+	if err := r.cleanUp(ctx, memcached); err != nil {
+		log.Error(err, "Failed to clean up memcached")
+		r.events.Eventf(memcached, corev1.EventTypeWarning, "CleanupFailed", "Failed to clean up: %s", err)
+		setCondition(memcached, typeCleanupFailedMemcached, metav1.ConditionTrue, "CleanupFailed", err.Error())
+		if err := r.updateStatus(ctx, memcached); err != nil {
+			return requeueWith(err)
+		}
+
+		if r.clock.Since(memcached.DeletionTimestamp.Time) < cleanupTimeout {
+			return requeueWith(err)
+		}
+		log.Info("cleanup timed out, releasing the finalizer", "timeout", cleanupTimeout)
+	}
+
+	patch := client.MergeFrom(memcached.DeepCopy())
+	controllerutil.RemoveFinalizer(memcached, memcachedFinalizer)
+	if err := r.k8.Patch(ctx, memcached, patch); err != nil {
+		log.Error(err, "Failed to remove finalizer")
+		return requeueWith(err)
+	}
+	r.events.Eventf(memcached, corev1.EventTypeNormal, "CleanedUp", "Cleaned up, released the finalizer")
+
+	return stop()
+}
+
+// cleanUp runs the cleanup steps in order and stops at the first failing step.
+// The operator doesn't run a proxy in front of memcached yet, draining it is
+// left out until it does.
+func (r *MemcachedReconciler) cleanUp(ctx context.Context, memcached *cachev1alpha1.Memcached) error {
+	if memcached.Spec.FlushOnDelete {
+		if err := r.flushAll(ctx, memcached); err != nil {
+			return fmt.Errorf("flush: %w", err)
+		}
+	}
+
+	if memcached.Spec.DeletionPolicy == cachev1alpha1.DeletionPolicyDelete {
+		if err := r.deleteClaims(ctx, memcached); err != nil {
+			return fmt.Errorf("delete PersistentVolumeClaims: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// flushAll flushes every running pod of the Memcached.
+func (r *MemcachedReconciler) flushAll(ctx context.Context, memcached *cachev1alpha1.Memcached) error {
+	pods, err := listPodsForMemcached(ctx, r.k8, memcached)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+			continue
+		}
+		if err := flushPod(ctx, r.mc, pod); err != nil {
+			errs = append(errs, fmt.Errorf("pod %s: %w", pod.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// deleteClaims deletes the PersistentVolumeClaims of the Memcached. The operator
+// creates no claims itself, so only claims owned by the Memcached or annotated
+// for it are deleted. Labels alone don't count, other tooling may set them.
+func (r *MemcachedReconciler) deleteClaims(ctx context.Context, memcached *cachev1alpha1.Memcached) error {
+	claims := &corev1.PersistentVolumeClaimList{}
+	if err := r.k8.List(ctx, claims, client.InNamespace(memcached.Namespace)); err != nil {
+		return err
+	}
+
+	for i := range claims.Items {
+		if !isClaimOf(memcached, &claims.Items[i]) {
+			continue
+		}
+		if err := r.k8.Delete(ctx, &claims.Items[i]); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func isClaimOf(memcached *cachev1alpha1.Memcached, claim *corev1.PersistentVolumeClaim) bool {
+	owned := slices.ContainsFunc(claim.OwnerReferences, func(owner metav1.OwnerReference) bool {
+		return owner.UID == memcached.UID
+	})
+	return owned || claim.Annotations[claimAnnotation] == memcached.Name
+}
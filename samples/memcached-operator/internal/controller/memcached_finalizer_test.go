@@ -0,0 +1,175 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	cachev1alpha1 "example.com/m/v2/api/v1alpha1"
+)
+
+var _ = Describe("Memcached Finalizer", func() {
+	const resourceName = "finalizer-memcached"
+	typeNamespacedName := types.NamespacedName{Name: resourceName, Namespace: "default"}
+
+	var memcachedServer *memcachedStub
+
+	BeforeEach(func() {
+		memcachedServer = newMemcachedStub(nil)
+		createMemcachedCR(resourceName, ctx, typeNamespacedName, &cachev1alpha1.Memcached{})
+	})
+
+	AfterEach(func() {
+		memcached := &cachev1alpha1.Memcached{}
+		if err := k8sClient.Get(ctx, typeNamespacedName, memcached); err == nil {
+			Expect(k8sClient.Delete(ctx, memcached)).To(Succeed())
+			removeFinalizer(typeNamespacedName)
+		}
+
+		dep := &appsv1.Deployment{}
+		if err := k8sClient.Get(ctx, typeNamespacedName, dep); err == nil {
+			Expect(k8sClient.Delete(ctx, dep)).To(Succeed())
+		}
+	})
+
+	It("should add the finalizer", func() {
+		r := newReconciler()
+
+		_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+		memcached := &cachev1alpha1.Memcached{}
+		Expect(k8sClient.Get(ctx, typeNamespacedName, memcached)).To(Succeed())
+		Expect(memcached.Finalizers).To(ContainElement(memcachedFinalizer))
+	})
+
+	It("should flush the pods and delete the claims before releasing the finalizer", func() {
+		updateMemcached(typeNamespacedName, func(m *cachev1alpha1.Memcached) {
+			m.Spec.FlushOnDelete = true
+			m.Spec.DeletionPolicy = cachev1alpha1.DeletionPolicyDelete
+		})
+		r := newReconciler()
+		r.mc = memcachedServer.cli
+		_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+		createOperationPod("finalizer-pod", "10.0.0.1", typeNamespacedName)
+		createClaim("finalizer-claim", typeNamespacedName, map[string]string{claimAnnotation: resourceName})
+		createClaim("labeled-claim", typeNamespacedName, nil)
+		labeledClaim := types.NamespacedName{Name: "labeled-claim", Namespace: typeNamespacedName.Namespace}
+		DeferCleanup(func() {
+			Expect(k8sClient.Delete(ctx, &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: labeledClaim.Name, Namespace: labeledClaim.Namespace},
+			})).To(Succeed())
+		})
+
+		By("Delete the Memcached")
+		deleteMemcached(typeNamespacedName)
+		_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+		Expect(memcachedServer.sent()).To(ConsistOf("10.0.0.1:11211 flush_all"))
+		expectClaimDeleted(types.NamespacedName{Name: "finalizer-claim", Namespace: typeNamespacedName.Namespace})
+		By("Keep the claim which only carries the labels of the Memcached")
+		claim := &corev1.PersistentVolumeClaim{}
+		Expect(k8sClient.Get(ctx, labeledClaim, claim)).To(Succeed())
+		Expect(claim.DeletionTimestamp).To(BeNil())
+		err := k8sClient.Get(ctx, typeNamespacedName, &cachev1alpha1.Memcached{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should retain the claims by default", func() {
+		r := newReconciler()
+		_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+		createClaim("retained-claim", typeNamespacedName, map[string]string{claimAnnotation: resourceName})
+		claimName := types.NamespacedName{Name: "retained-claim", Namespace: typeNamespacedName.Namespace}
+		DeferCleanup(func() {
+			Expect(k8sClient.Delete(ctx, &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: claimName.Name, Namespace: claimName.Namespace},
+			})).To(Succeed())
+		})
+
+		deleteMemcached(typeNamespacedName)
+		_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+		claim := &corev1.PersistentVolumeClaim{}
+		Expect(k8sClient.Get(ctx, claimName, claim)).To(Succeed())
+		Expect(claim.DeletionTimestamp).To(BeNil())
+	})
+
+	It("should report a failed cleanup and keep the finalizer", func() {
+		updateMemcached(typeNamespacedName, func(m *cachev1alpha1.Memcached) {
+			m.Spec.FlushOnDelete = true
+		})
+		memcachedServer.failFor("10.0.0.1:11211", "flush_all")
+		r := newReconciler()
+		r.mc = memcachedServer.cli
+		_, _ = reconcileOnce(ctx, r, typeNamespacedName, false)
+
+		createOperationPod("failing-finalizer-pod", "10.0.0.1", typeNamespacedName)
+
+		deleteMemcached(typeNamespacedName)
+		_, err := reconcileOnce(ctx, r, typeNamespacedName, true)
+		Expect(err).To(MatchError(ContainSubstring("connection refused")))
+
+		expectConditionOfType(typeCleanupFailedMemcached, metav1.ConditionTrue, "CleanupFailed", typeNamespacedName)
+		memcached := &cachev1alpha1.Memcached{}
+		Expect(k8sClient.Get(ctx, typeNamespacedName, memcached)).To(Succeed())
+		Expect(memcached.Finalizers).To(ContainElement(memcachedFinalizer))
+	})
+})
+
+func deleteMemcached(t types.NamespacedName) {
+	memcached := &cachev1alpha1.Memcached{}
+	Expect(k8sClient.Get(ctx, t, memcached)).To(Succeed())
+	Expect(k8sClient.Delete(ctx, memcached)).To(Succeed())
+}
+
+func createClaim(name string, memcached types.NamespacedName, annotations map[string]string) {
+	claim := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   memcached.Namespace,
+			Labels:      labelsForMemcached(memcached.Name),
+			Annotations: annotations,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+			},
+		},
+	}
+	Expect(k8sClient.Create(ctx, claim)).To(Succeed())
+}
+
+// expectClaimDeleted accepts a claim which is still terminating because of the
+// 'kubernetes.io/pvc-protection' finalizer.
+func expectClaimDeleted(t types.NamespacedName) {
+	claim := &corev1.PersistentVolumeClaim{}
+	err := k8sClient.Get(ctx, t, claim)
+	if apierrors.IsNotFound(err) {
+		return
+	}
+	Expect(err).NotTo(HaveOccurred())
+	Expect(claim.DeletionTimestamp).NotTo(BeNil())
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+
+	cachev1alpha1 "example.com/m/v2/api/v1alpha1"
+)
+
+const (
+	defaultTerminationGracePeriodSeconds = int64(30)
+
+	// drainDelaySeconds is the time a terminating pod keeps serving after it was
+	// removed from the Service endpoints, until kube-proxy and clients caught up.
+	drainDelaySeconds = 5
+)
+
+// lifecycleForMemcached returns a preStop hook draining the connections before
+// memcached gets its graceful shutdown signal. A terminating pod is removed from
+// the Service endpoints right away, but it takes a moment until the change
+// reached every node, so memcached keeps serving for the drain delay. After that
+// SIGUSR1 makes memcached 1.6 stop accepting connections and exit once the
+// in-flight requests are done. The hook waits for memcached to exit so that the
+// kubelet doesn't send SIGTERM before, which would close the connections right
+// away. memcached runs as PID 1 in the container.
+func lifecycleForMemcached() *corev1.Lifecycle {
+	script := fmt.Sprintf(
+		"sleep %d; kill -USR1 1; while kill -0 1 2>/dev/null; do sleep 1; done",
+		drainDelaySeconds,
+	)
+
+	return &corev1.Lifecycle{
+		PreStop: &corev1.LifecycleHandler{
+			Exec: &corev1.ExecAction{Command: []string{"sh", "-c", script}},
+		},
+	}
+}
+
+// terminationGracePeriodFor returns the termination grace period of the
+// memcached pods which bounds the drain delay plus the graceful shutdown.
+func terminationGracePeriodFor(memcached *cachev1alpha1.Memcached) *int64 {
+	return ptr.To(ptr.Deref(memcached.Spec.TerminationGracePeriodSeconds, defaultTerminationGracePeriodSeconds))
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	reconcileRan     = "ran"
+	reconcileSkipped = "skipped"
+)
+
+// reconcilesTotal counts the reconciliations of Memcached resources which ran
+// and the watch events which were skipped by the predicates. It is served with
+// the controller-runtime metrics on the metrics endpoint of the manager.
+var reconcilesTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "memcached_reconciles_total",
+		Help: "Number of Memcached reconciliations which ran and of watch events skipped by the predicates.",
+	},
+	[]string{"outcome"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(reconcilesTotal)
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// memcachedChanged passes changes of the spec, the annotations, e.g. pausing
+// the reconciliation, and the start of the deletion. The status updates of the
+// reconciler itself are filtered out, they don't change the generation.
+func memcachedChanged() predicate.Predicate {
+	return predicate.Or[client.Object](
+		predicate.GenerationChangedPredicate{},
+		predicate.AnnotationChangedPredicate{},
+		predicate.Funcs{UpdateFunc: deletionStarted},
+	)
+}
+
+// deletionStarted is true when the update sets the deletion timestamp.
+func deletionStarted(e event.UpdateEvent) bool {
+	if e.ObjectOld == nil || e.ObjectNew == nil {
+		return false
+	}
+
+	return e.ObjectOld.GetDeletionTimestamp().IsZero() && !e.ObjectNew.GetDeletionTimestamp().IsZero()
+}
+
+// deploymentChanged passes changes of the Deployment spec, they are reverted
+// if they drift from the Memcached, and of the replica counts the conditions
+// are computed from. Changes of metadata, e.g. labels added by other tools,
+// and of the Deployment's own conditions are filtered out.
+func deploymentChanged() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			old, okOld := e.ObjectOld.(*appsv1.Deployment)
+			updated, okNew := e.ObjectNew.(*appsv1.Deployment)
+			if !okOld || !okNew {
+				return false
+			}
+
+			return old.Generation != updated.Generation || replicasChanged(old.Status, updated.Status)
+		},
+	}
+}
+
+func replicasChanged(old, updated appsv1.DeploymentStatus) bool {
+	return old.ObservedGeneration != updated.ObservedGeneration ||
+		old.Replicas != updated.Replicas ||
+		old.UpdatedReplicas != updated.UpdatedReplicas ||
+		old.ReadyReplicas != updated.ReadyReplicas ||
+		old.AvailableReplicas != updated.AvailableReplicas
+}
+
+// countSkipped counts the events filtered out by the predicate.
+func countSkipped(p predicate.Predicate) predicate.Predicate {
+	skip := func(passed bool) bool {
+		if !passed {
+			reconcilesTotal.WithLabelValues(reconcileSkipped).Inc()
+		}
+		return passed
+	}
+
+	return predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return skip(p.Create(e)) },
+		UpdateFunc:  func(e event.UpdateEvent) bool { return skip(p.Update(e)) },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return skip(p.Delete(e)) },
+		GenericFunc: func(e event.GenericEvent) bool { return skip(p.Generic(e)) },
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	cachev1alpha1 "example.com/m/v2/api/v1alpha1"
+)
+
+var _ = Describe("Memcached Predicates", func() {
+	update := func(old, updated client.Object) event.UpdateEvent {
+		return event.UpdateEvent{ObjectOld: old, ObjectNew: updated}
+	}
+
+	Context("Memcached", func() {
+		memcached := func(mutate func(*cachev1alpha1.Memcached)) *cachev1alpha1.Memcached {
+			m := &cachev1alpha1.Memcached{
+				ObjectMeta: metav1.ObjectMeta{Name: "predicate-memcached", Namespace: "default", Generation: 1},
+				Spec:       cachev1alpha1.MemcachedSpec{Size: 1},
+			}
+			if mutate != nil {
+				mutate(m)
+			}
+			return m
+		}
+
+		DescribeTable("should pass updates",
+			func(mutate func(*cachev1alpha1.Memcached), passes bool) {
+				Expect(memcachedChanged().Update(update(memcached(nil), memcached(mutate)))).To(Equal(passes))
+			},
+			Entry("of the spec", func(m *cachev1alpha1.Memcached) {
+				m.Generation = 2
+				m.Spec.Size = 2
+			}, true),
+			Entry("of the annotations", func(m *cachev1alpha1.Memcached) {
+				m.Annotations = map[string]string{pausedAnnotation: "true"}
+			}, true),
+			Entry("starting the deletion", func(m *cachev1alpha1.Memcached) {
+				m.DeletionTimestamp = ptr.To(metav1.Now())
+			}, true),
+			Entry("but not of the status", func(m *cachev1alpha1.Memcached) {
+				m.Status.Conditions = []metav1.Condition{{Type: typeAvailableMemcached}}
+			}, false),
+			Entry("but not of the finalizers", func(m *cachev1alpha1.Memcached) {
+				m.Finalizers = []string{memcachedFinalizer}
+			}, false),
+		)
+	})
+
+	Context("Deployment", func() {
+		deployment := func(mutate func(*appsv1.Deployment)) *appsv1.Deployment {
+			dep := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "predicate-memcached", Namespace: "default", Generation: 1},
+				Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(int32(1))},
+				Status:     appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 1},
+			}
+			if mutate != nil {
+				mutate(dep)
+			}
+			return dep
+		}
+
+		DescribeTable("should pass updates",
+			func(mutate func(*appsv1.Deployment), passes bool) {
+				Expect(deploymentChanged().Update(update(deployment(nil), deployment(mutate)))).To(Equal(passes))
+			},
+			Entry("of the spec", func(dep *appsv1.Deployment) {
+				dep.Generation = 2
+				dep.Spec.Replicas = ptr.To(int32(2))
+			}, true),
+			Entry("of the available replicas", func(dep *appsv1.Deployment) {
+				dep.Status.AvailableReplicas = 1
+			}, true),
+			Entry("of the observed generation", func(dep *appsv1.Deployment) {
+				dep.Status.ObservedGeneration = 2
+			}, true),
+			Entry("but not of the labels", func(dep *appsv1.Deployment) {
+				dep.Labels = map[string]string{"team": "cache"}
+			}, false),
+			Entry("but not of the deployment conditions", func(dep *appsv1.Deployment) {
+				dep.Status.Conditions = []appsv1.DeploymentCondition{{Type: appsv1.DeploymentProgressing}}
+			}, false),
+		)
+
+		It("should pass creations and deletions", func() {
+			Expect(deploymentChanged().Create(event.CreateEvent{Object: deployment(nil)})).To(BeTrue())
+			Expect(deploymentChanged().Delete(event.DeleteEvent{Object: deployment(nil)})).To(BeTrue())
+		})
+	})
+
+	Context("Pod", func() {
+		DescribeTable("should cache and watch",
+			func(podLabels map[string]string, passes bool) {
+				pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "predicate-pod", Namespace: "default", Labels: podLabels}}
+				var selector labels.Selector
+				for obj, byObject := range CacheOptions().ByObject {
+					if _, ok := obj.(*corev1.Pod); ok {
+						selector = byObject.Label
+					}
+				}
+
+				Expect(selector).NotTo(BeNil())
+				Expect(selector.Matches(labels.Set(podLabels))).To(Equal(passes))
+				Expect(isMemcachedPod(pod)).To(Equal(passes))
+			},
+			Entry("the memcached pods", labelsForMemcached("predicate-memcached"), true),
+			Entry("but not other pods of the project", map[string]string{nameLabel: "project"}, false),
+			Entry("but not unrelated pods", map[string]string{"app": "web"}, false),
+		)
+	})
+
+	It("should count the skipped events", func() {
+		skipped := testutil.ToFloat64(reconcilesTotal.WithLabelValues(reconcileSkipped))
+		p := countSkipped(deploymentChanged())
+
+		Expect(p.Update(update(&appsv1.Deployment{}, &appsv1.Deployment{}))).To(BeFalse())
+		Expect(p.Create(event.CreateEvent{Object: &appsv1.Deployment{}})).To(BeTrue())
+
+		Expect(testutil.ToFloat64(reconcilesTotal.WithLabelValues(reconcileSkipped))).To(Equal(skipped + 1))
+	})
+})
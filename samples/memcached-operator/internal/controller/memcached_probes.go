@@ -0,0 +1,78 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	cachev1alpha1 "example.com/m/v2/api/v1alpha1"
+)
+
+const memcachedPort = 11211
+
+// memcachedProbe returns an exec probe which sends a command over the memcached
+// text protocol and succeeds only if the reply starts with the expected prefix.
+// Opening a TCP connection is not enough, a wedged memcached still accepts
+// connections but never answers. The alpine image ships busybox 'nc'.
+func memcachedProbe(command, expectedReply string) *corev1.Probe {
+	script := fmt.Sprintf(
+		`printf '%s\r\nquit\r\n' | nc -w 2 127.0.0.1 %d | grep -q '^%s'`,
+		command, memcachedPort, expectedReply,
+	)
+
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			Exec: &corev1.ExecAction{Command: []string{"sh", "-c", script}},
+		},
+		TimeoutSeconds: 3,
+	}
+}
+
+// probesForMemcached returns the readiness, liveness and startup probe of the
+// memcached container. Probes configured in the spec replace the defaults.
+func probesForMemcached(memcached *cachev1alpha1.Memcached) (*corev1.Probe, *corev1.Probe, *corev1.Probe) {
+	// ready once memcached serves statistics which requires a working worker thread
+	readiness := memcachedProbe("stats", "STAT pid")
+	readiness.PeriodSeconds = 10
+	readiness.FailureThreshold = 3
+
+	// alive as long as memcached answers, restart a wedged process
+	liveness := memcachedProbe("version", "VERSION ")
+	liveness.PeriodSeconds = 20
+	liveness.FailureThreshold = 3
+
+	// give memcached up to a minute to start before the liveness probe kicks in
+	startup := memcachedProbe("version", "VERSION ")
+	startup.PeriodSeconds = 2
+	startup.FailureThreshold = 30
+
+	if probes := memcached.Spec.Probes; probes != nil {
+		if probes.Readiness != nil {
+			readiness = probes.Readiness.DeepCopy()
+		}
+		if probes.Liveness != nil {
+			liveness = probes.Liveness.DeepCopy()
+		}
+		if probes.Startup != nil {
+			startup = probes.Startup.DeepCopy()
+		}
+	}
+
+	return readiness, liveness, startup
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"example.com/m/v2/internal/controller/infra"
+)
+
+const memcachedCommandTimeout = 5 * time.Second
+
+// podAddr returns the address of the memcached container of the pod.
+func podAddr(pod corev1.Pod) (string, error) {
+	if pod.Status.PodIP == "" {
+		return "", fmt.Errorf("pod has no IP")
+	}
+
+	return net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(memcachedPort)), nil
+}
+
+// flushPod invalidates all items of the pod with 'flush_all'.
+func flushPod(ctx context.Context, mc *infra.MemcachedCliImpl, pod corev1.Pod) error {
+	addr, err := podAddr(pod)
+	if err != nil {
+		return err
+	}
+	return mc.FlushAll(ctx, addr)
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/utils/ptr"
+
+	cachev1alpha1 "example.com/m/v2/api/v1alpha1"
+)
+
+// restartedAtAnnotation on the pod template holds the spec.restartedAt of the
+// Memcached. Changing it makes the Deployment roll its pods, the same way
+// 'kubectl rollout restart' does. The default rolling update strategy of the
+// Deployment replaces one pod at a time without reducing the available pods.
+const restartedAtAnnotation = "cache.example.com/restartedAt"
+
+// restartedAtFor returns the value of the restart annotation or an empty
+// string if no restart was requested.
+func restartedAtFor(memcached *cachev1alpha1.Memcached) string {
+	if memcached.Spec.RestartedAt == nil {
+		return ""
+	}
+	return memcached.Spec.RestartedAt.UTC().Format(time.RFC3339)
+}
+
+// restartRequested is true if the pod template doesn't carry the restart
+// timestamp of the spec yet.
+func restartRequested(memcached *cachev1alpha1.Memcached, dep *appsv1.Deployment) bool {
+	restartedAt := restartedAtFor(memcached)
+	return restartedAt != "" && dep.Spec.Template.Annotations[restartedAtAnnotation] != restartedAt
+}
+
+func stampRestart(memcached *cachev1alpha1.Memcached, dep *appsv1.Deployment) {
+	restartedAt := restartedAtFor(memcached)
+	if restartedAt == "" {
+		return
+	}
+	if dep.Spec.Template.Annotations == nil {
+		dep.Spec.Template.Annotations = map[string]string{}
+	}
+	dep.Spec.Template.Annotations[restartedAtAnnotation] = restartedAt
+}
+
+// recordCompletedRestart records the requested restart in the status once the
+// Deployment rolled all pods with the stamped template.
+func recordCompletedRestart(memcached *cachev1alpha1.Memcached, dep *appsv1.Deployment) {
+	if restartRequested(memcached, dep) || restartedAtFor(memcached) == "" || !rolloutComplete(dep) {
+		return
+	}
+	memcached.Status.LastCompletedRestartAt = memcached.Spec.RestartedAt.DeepCopy()
+}
+
+// rolloutComplete is true if the Deployment controller observed the latest
+// template and all replicas are updated and available.
+func rolloutComplete(dep *appsv1.Deployment) bool {
+	replicas := ptr.Deref(dep.Spec.Replicas, 1)
+	return dep.Status.ObservedGeneration >= dep.Generation &&
+		dep.Status.UpdatedReplicas == replicas &&
+		dep.Status.Replicas == replicas &&
+		dep.Status.AvailableReplicas == replicas
+}
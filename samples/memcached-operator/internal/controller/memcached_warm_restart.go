@@ -0,0 +1,367 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	cachev1alpha1 "example.com/m/v2/api/v1alpha1"
+)
+
+const (
+	memoryLimitMB = 64
+
+	memoryFileVolume = "memory-file"
+	memoryFileDir    = "/var/cache/memcached"
+	memoryFilePath   = memoryFileDir + "/memory_file"
+
+	// inPlaceUpdateAnnotation on the Deployment holds the memcached image the
+	// pods are updated to in place, the Deployment is paused meanwhile.
+	inPlaceUpdateAnnotation = "cache.example.com/updatingInPlace"
+	// imageUpdatedAtAnnotation on a pod holds the time its memcached image was
+	// changed in place.
+	imageUpdatedAtAnnotation = "cache.example.com/imageUpdatedAt"
+	// revisionAnnotation is the revision the Deployment controller sets on its
+	// ReplicaSets, the first ReplicaSet of a Deployment has revision 1.
+	revisionAnnotation = "deployment.kubernetes.io/revision"
+)
+
+// imageField is the drifted field of a new memcached image.
+var imageField = fmt.Sprintf("spec.template.spec.containers[%s].image", memcachedContainerName)
+
+func warmRestartEnabled(memcached *cachev1alpha1.Memcached) bool {
+	return memcached.Spec.WarmRestart != nil && memcached.Spec.WarmRestart.Enabled
+}
+
+// commandForMemcached returns the memcached command. With warm restart the
+// cache lives in a memory file which memcached 1.6 restores on start, if it was
+// stopped gracefully with SIGUSR1 by the preStop hook or 'shutdown graceful'.
+// Only then -A enables the shutdown command, the Restart operation restarts the
+// container in place with it. The text protocol has no authentication, every
+// client reaching the port can stop memcached with -A. Without warm restart the
+// Restart operation deletes the pods instead.
+func commandForMemcached(memcached *cachev1alpha1.Memcached) []string {
+	command := []string{"memcached", fmt.Sprintf("--memory-limit=%d", memoryLimitMB), "-o", "modern", "-v"}
+	if warmRestartEnabled(memcached) {
+		command = append(command, "-A", "-e", memoryFilePath)
+	}
+
+	return command
+}
+
+// volumesForMemcached returns the memory backed emptyDir holding the memory
+// file. It is kept for the lifetime of the pod, so only container restarts are
+// warm and a replaced pod starts cold. memcached restores the file across
+// versions as long as the memory settings are the same, a new image is
+// therefore rolled out by restarting the containers in place, see
+// updateInPlace.
+func volumesForMemcached(memcached *cachev1alpha1.Memcached) ([]corev1.Volume, []corev1.VolumeMount) {
+	if !warmRestartEnabled(memcached) {
+		return nil, nil
+	}
+
+	// the memory file holds the whole cache plus a small metadata file
+	sizeLimit := resource.MustParse(fmt.Sprintf("%dMi", memoryLimitMB+8))
+	volumes := []corev1.Volume{{
+		Name: memoryFileVolume,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{
+				Medium:    corev1.StorageMediumMemory,
+				SizeLimit: &sizeLimit,
+			},
+		},
+	}}
+	mounts := []corev1.VolumeMount{{
+		Name:      memoryFileVolume,
+		MountPath: memoryFileDir,
+	}}
+
+	return volumes, mounts
+}
+
+// lastRestartOf classifies the most recent restart of the memcached container.
+// A container restarted after a graceful stop (exit code 0) restored the memory
+// file if warm restart is enabled, a crashed or killed container starts cold.
+// The first start of a container is no restart, nil is returned for it, unless
+// a rollout created the pod. The pod replaced one of the previous revision and
+// started cold.
+func lastRestartOf(
+	pod *corev1.Pod,
+	cs *corev1.ContainerStatus,
+	warmRestart, rolledOut bool,
+) *cachev1alpha1.MemcachedRestartStatus {
+	if cs.State.Running == nil || cs.State.Running.StartedAt.IsZero() || (cs.RestartCount == 0 && !rolledOut) {
+		return nil
+	}
+
+	restart := &cachev1alpha1.MemcachedRestartStatus{
+		Pod:  pod.Name,
+		Type: cachev1alpha1.RestartTypeCold,
+		Time: cs.State.Running.StartedAt,
+	}
+
+	last := cs.LastTerminationState.Terminated
+	if warmRestart && cs.RestartCount > 0 && last != nil && last.ExitCode == 0 {
+		restart.Type = cachev1alpha1.RestartTypeWarm
+	}
+
+	return restart
+}
+
+// rolledOutReplicaSets returns the names of the ReplicaSets of the Memcached
+// which a rollout created, i.e. every ReplicaSet but the first of the
+// Deployment.
+func (r *MemcachedReconciler) rolledOutReplicaSets(
+	ctx context.Context,
+	memcached *cachev1alpha1.Memcached,
+) (map[string]bool, error) {
+	replicaSets := &appsv1.ReplicaSetList{}
+	if err := r.k8.List(ctx, replicaSets,
+		client.InNamespace(memcached.Namespace),
+		client.MatchingLabels(labelsForMemcached(memcached.Name)),
+	); err != nil {
+		return nil, err
+	}
+
+	rolledOut := map[string]bool{}
+	for _, rs := range replicaSets.Items {
+		if revision, err := strconv.Atoi(rs.Annotations[revisionAnnotation]); err == nil && revision > 1 {
+			rolledOut[rs.Name] = true
+		}
+	}
+
+	return rolledOut, nil
+}
+
+// replicaSetOf returns the name of the ReplicaSet controlling the pod.
+func replicaSetOf(pod *corev1.Pod) string {
+	if owner := metav1.GetControllerOf(pod); owner != nil && owner.Kind == "ReplicaSet" {
+		return owner.Name
+	}
+	return ""
+}
+
+// latestRestart returns the most recent restart of all pods.
+func latestRestart(pods []cachev1alpha1.MemcachedPodStatus) *cachev1alpha1.MemcachedRestartStatus {
+	var latest *cachev1alpha1.MemcachedRestartStatus
+	for _, pod := range pods {
+		if pod.LastRestart == nil {
+			continue
+		}
+		if latest == nil || latest.Time.Before(&pod.LastRestart.Time) {
+			latest = pod.LastRestart
+		}
+	}
+
+	return latest
+}
+
+// updatesInPlace is true if the Deployment gets a new memcached image in place
+// or is already updated in place. The image is changed in place only with warm
+// restart, after the previous rollout completed and if nothing else changed.
+// Every other change of the Deployment rolls the pods, this ends an update in
+// place as well.
+func updatesInPlace(
+	memcached *cachev1alpha1.Memcached,
+	live *appsv1.Deployment,
+	resize, restart bool,
+	drifted []string,
+) bool {
+	if !warmRestartEnabled(memcached) || resize || restart {
+		return false
+	}
+
+	newImage := slices.Equal(drifted, []string{imageField})
+	if live.Annotations[inPlaceUpdateAnnotation] != "" {
+		return newImage || len(drifted) == 0
+	}
+
+	return newImage && rolloutComplete(live)
+}
+
+// updateInPlace rolls a new memcached image out without replacing the pods.
+// The Deployment is paused with the new image, its controller creates no new
+// ReplicaSet then. The image of one pod after another is changed, the kubelet
+// restarts the memcached container in place. The preStop hook stops memcached
+// gracefully and the new version restores the cache from the memory file. Once
+// every pod runs the new image, their ReplicaSet gets the new image as well
+// and the Deployment is resumed. Its controller finds the ReplicaSet matching
+// the template and rolls nothing. The Deployment is reported as changed when
+// it was resumed.
+func (r *MemcachedReconciler) updateInPlace(
+	ctx context.Context,
+	memcached *cachev1alpha1.Memcached,
+	dep, live *appsv1.Deployment,
+) (*appsv1.Deployment, bool, error) {
+	log := logf.FromContext(ctx).WithValues("Deployment.Namespace", live.Namespace, "Deployment.Name", live.Name)
+
+	image := r.image()
+	pods, err := listPodsForMemcached(ctx, r.k8, memcached)
+	if err != nil {
+		log.Error(err, "Failed to list pods for memcached")
+		return nil, false, err
+	}
+
+	next, wait := podToUpdate(pods, image, memcached.Spec.Size)
+	if next == nil && !wait {
+		if err := r.updateReplicaSets(ctx, pods, image); err != nil {
+			log.Error(err, "Failed to update the ReplicaSets of the pods")
+			return nil, false, err
+		}
+		if err := r.apply(ctx, dep); err != nil {
+			log.Error(err, "Failed to resume Deployment")
+			return nil, false, err
+		}
+
+		log.Info("updated the pods in place", "image", image)
+		r.events.Eventf(memcached, corev1.EventTypeNormal, "UpdatedInPlace",
+			"Updated the %d pods of Deployment %s in place to %s", len(pods), live.Name, image)
+		return dep, true, nil
+	}
+
+	if dep.Annotations == nil {
+		dep.Annotations = map[string]string{}
+	}
+	dep.Annotations[inPlaceUpdateAnnotation] = image
+	dep.Spec.Paused = true
+	if err := r.apply(ctx, dep); err != nil {
+		log.Error(err, "Failed to pause Deployment")
+		return nil, false, err
+	}
+	if next == nil {
+		return dep, false, nil
+	}
+
+	log.Info("updating pod in place", "pod", next.Name, "image", image)
+	if err := r.updatePodImage(ctx, next, image); err != nil {
+		log.Error(err, "Failed to update pod in place", "pod", next.Name)
+		r.events.Eventf(memcached, corev1.EventTypeWarning, "UpdateInPlaceFailed",
+			"Failed to update pod %s in place to %s: %s", next.Name, image, err)
+		return nil, false, err
+	}
+	r.events.Eventf(memcached, corev1.EventTypeNormal, "UpdatingInPlace",
+		"Updating pod %s in place to %s", next.Name, image)
+
+	return dep, false, nil
+}
+
+// podToUpdate returns the next pod whose memcached container runs another
+// image. It waits while fewer pods than the size of the Memcached are ready or
+// a pod is restarting with the new image, only one pod is updated at a time.
+func podToUpdate(pods []corev1.Pod, image string, size int32) (*corev1.Pod, bool) {
+	if readyPods(pods) < size {
+		return nil, true
+	}
+
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+	var next *corev1.Pod
+	for i := range pods {
+		pod := &pods[i]
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		if containerImage(pod) != image {
+			if next == nil {
+				next = pod
+			}
+			continue
+		}
+		if updatedAt := imageUpdatedAt(pod); updatedAt != nil && !restartedAndReady(*pod, updatedAt) {
+			return nil, true
+		}
+	}
+
+	return next, false
+}
+
+// updatePodImage changes the memcached image of the running pod and records
+// when it did, the kubelet restarts the container with the new image.
+func (r *MemcachedReconciler) updatePodImage(ctx context.Context, pod *corev1.Pod, image string) error {
+	original := pod.DeepCopy()
+	if container := containerNamed(pod.Spec.Containers, memcachedContainerName); container != nil {
+		container.Image = image
+	}
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[imageUpdatedAtAnnotation] = r.clock.Now().UTC().Format(time.RFC3339)
+
+	return r.k8.Patch(ctx, pod, client.MergeFrom(original))
+}
+
+// updateReplicaSets sets the new memcached image in the template of the
+// ReplicaSets of the pods, so that they match the template of the Deployment.
+func (r *MemcachedReconciler) updateReplicaSets(ctx context.Context, pods []corev1.Pod, image string) error {
+	var names []string
+	for i := range pods {
+		if name := replicaSetOf(&pods[i]); name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range names {
+		rs := &appsv1.ReplicaSet{}
+		if err := r.k8.Get(ctx, types.NamespacedName{Name: name, Namespace: pods[0].Namespace}, rs); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+
+		container := containerNamed(rs.Spec.Template.Spec.Containers, memcachedContainerName)
+		if container == nil || container.Image == image {
+			continue
+		}
+		container.Image = image
+		if err := r.k8.Update(ctx, rs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func containerImage(pod *corev1.Pod) string {
+	if container := containerNamed(pod.Spec.Containers, memcachedContainerName); container != nil {
+		return container.Image
+	}
+	return ""
+}
+
+// imageUpdatedAt returns when the image of the pod was changed in place or nil
+// if it wasn't.
+func imageUpdatedAt(pod *corev1.Pod) *metav1.Time {
+	updatedAt, err := time.Parse(time.RFC3339, pod.Annotations[imageUpdatedAtAnnotation])
+	if err != nil {
+		return nil
+	}
+	return &metav1.Time{Time: updatedAt}
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/config"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+
+	cachev1alpha1 "example.com/m/v2/api/v1alpha1"
+)
+
+// The tests in this file run the controller in a manager. The status must
+// converge well below the one minute the controller used to poll with.
+const convergenceTimeout = 10 * time.Second
+
+var _ = Describe("Memcached Watches", func() {
+	const resourceName = "watched-memcached"
+	typeNamespacedName := types.NamespacedName{Name: resourceName, Namespace: "default"}
+
+	BeforeEach(func() {
+		startManager()
+		createMemcachedCR(resourceName, ctx, typeNamespacedName, &cachev1alpha1.Memcached{})
+	})
+
+	AfterEach(func() {
+		memcached := &cachev1alpha1.Memcached{}
+		Expect(k8sClient.Get(ctx, typeNamespacedName, memcached)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, memcached)).To(Succeed())
+		Eventually(func() bool {
+			return apierrors.IsNotFound(k8sClient.Get(ctx, typeNamespacedName, &cachev1alpha1.Memcached{}))
+		}, convergenceTimeout).Should(BeTrue())
+
+		dep := &appsv1.Deployment{}
+		if err := k8sClient.Get(ctx, typeNamespacedName, dep); err == nil {
+			Expect(k8sClient.Delete(ctx, dep)).To(Succeed())
+		}
+	})
+
+	It("should become available when the deployment reports available replicas", func() {
+		By("Waiting for the deployment")
+		Eventually(func() error {
+			return k8sClient.Get(ctx, typeNamespacedName, &appsv1.Deployment{})
+		}, convergenceTimeout).Should(Succeed())
+		eventuallyCondition(typeAvailableMemcached, metav1.ConditionFalse, reasonMinimumReplicasUnavailable, typeNamespacedName)
+
+		By("Reporting available replicas like the deployment controller would")
+		setDeploymentStatus(1, typeNamespacedName)
+
+		eventuallyCondition(typeAvailableMemcached, metav1.ConditionTrue, reasonMinimumReplicasAvailable, typeNamespacedName)
+		eventuallyCondition(typeProgressingMemcached, metav1.ConditionFalse, reasonRolloutComplete, typeNamespacedName)
+	})
+
+	It("should not reconcile again after its own status updates", func() {
+		eventuallyCondition(typeAvailableMemcached, metav1.ConditionFalse, reasonMinimumReplicasUnavailable, typeNamespacedName)
+
+		By("Waiting for the reconciliations triggered by the creation to settle")
+		ran := func() float64 { return testutil.ToFloat64(reconcilesTotal.WithLabelValues(reconcileRan)) }
+		Eventually(func() float64 {
+			before := ran()
+			time.Sleep(time.Second)
+			return ran() - before
+		}, convergenceTimeout).Should(BeZero())
+
+		Consistently(ran, 3*time.Second).Should(Equal(ran()))
+	})
+
+	It("should revert a resized deployment", func() {
+		Eventually(func() error {
+			return k8sClient.Get(ctx, typeNamespacedName, &appsv1.Deployment{})
+		}, convergenceTimeout).Should(Succeed())
+
+		resizeDeploymentTo(2, typeNamespacedName)
+
+		Eventually(func() *int32 {
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			return dep.Spec.Replicas
+		}, convergenceTimeout).Should(HaveValue(Equal(int32(1))))
+	})
+
+	It("should report pod status changes", func() {
+		Eventually(func() error {
+			return k8sClient.Get(ctx, typeNamespacedName, &appsv1.Deployment{})
+		}, convergenceTimeout).Should(Succeed())
+
+		By("Creating a pod stuck pulling its image")
+		createPodWith(corev1.ContainerStatus{
+			State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+				Reason:  "ImagePullBackOff",
+				Message: "Back-off pulling image",
+			}},
+		}, typeNamespacedName)
+
+		eventuallyCondition(typeDegradedMemcached, metav1.ConditionTrue, reasonImagePullFailed, typeNamespacedName)
+		Eventually(func() []cachev1alpha1.MemcachedPodStatus {
+			memcached := &cachev1alpha1.Memcached{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, memcached)).To(Succeed())
+			return memcached.Status.Pods
+		}, convergenceTimeout).Should(HaveLen(1))
+	})
+})
+
+// startManager runs the Memcached controller in a manager until the end of the
+// current spec.
+func startManager() {
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:     scheme.Scheme,
+		Cache:      CacheOptions(),
+		Metrics:    metricsserver.Options{BindAddress: "0"},
+		Controller: config.Controller{SkipNameValidation: ptr.To(true)},
+	})
+	Expect(err).NotTo(HaveOccurred())
+	Expect(NewReconciler(
+		mgr.GetScheme(),
+		mgr.GetClient(),
+		mgr.GetEventRecorderFor("memcached-controller"),
+		ctrl.SetControllerReference,
+	).SetupWithManager(mgr)).To(Succeed())
+
+	mgrCtx, mgrCancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer GinkgoRecover()
+		defer close(done)
+		Expect(mgr.Start(mgrCtx)).To(Succeed())
+	}()
+	DeferCleanup(func() {
+		mgrCancel()
+		<-done
+	})
+}
+
+func eventuallyCondition(conditionType string, status metav1.ConditionStatus, reason string, t types.NamespacedName) {
+	Eventually(func(g Gomega) {
+		memcached := &cachev1alpha1.Memcached{}
+		g.Expect(k8sClient.Get(ctx, t, memcached)).To(Succeed())
+		condition := meta.FindStatusCondition(memcached.Status.Conditions, conditionType)
+		g.Expect(condition).NotTo(BeNil())
+		g.Expect(condition.Status).To(Equal(status))
+		g.Expect(condition.Reason).To(Equal(reason))
+	}, convergenceTimeout).Should(Succeed())
+}
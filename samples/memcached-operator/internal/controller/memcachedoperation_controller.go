@@ -0,0 +1,557 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cachev1alpha1 "example.com/m/v2/api/v1alpha1"
+	"example.com/m/v2/internal/controller/infra"
+)
+
+const (
+	defaultOperationTTLSeconds = int32(3600)
+
+	// restartTimeout is the time a pod gets to become ready again after a restart
+	restartTimeout = 5 * time.Minute
+)
+
+// operationStats are the keys of 'stats' the Stats action records per pod. A
+// fixed set keeps the status of the operation bounded, whatever the memcached
+// version replies.
+var operationStats = []string{
+	"pid", "uptime", "version", "curr_connections", "total_connections",
+	"curr_items", "total_items", "bytes", "limit_maxbytes",
+	"cmd_get", "cmd_set", "get_hits", "get_misses", "evictions",
+}
+
+// MemcachedOperationReconciler reconciles a MemcachedOperation object. It runs
+// the action of the operation against every pod of the referenced Memcached
+// over the memcached text protocol.
+type MemcachedOperationReconciler struct {
+	k8    *infra.K8CliImpl
+	mc    *infra.MemcachedCliImpl
+	clock *infra.ClockImpl
+}
+
+func NewOperationReconciler(k8 client.Client) *MemcachedOperationReconciler {
+	return &MemcachedOperationReconciler{
+		k8:    infra.NewK8CliImpl(k8),
+		mc:    infra.NewMemcachedCliImpl(memcachedCommandTimeout),
+		clock: infra.NewClockImpl(),
+	}
+}
+
+// +kubebuilder:rbac:groups=cache.example.com,resources=memcachedoperations,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cache.example.com,resources=memcachedoperations/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=cache.example.com,resources=memcachedoperations/finalizers,verbs=update
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;delete
+
+// Reconcile runs the operation until it reaches a terminal phase and deletes it
+// after its TTL expired. Flush and Stats run against all pods at once, Restart
+// restarts one pod after another and waits until the restarted pod is ready
+// or replaced. The watch of the pods triggers the next reconciliation, the
+// operation is only requeued for the restart timeout.
+func (r *MemcachedOperationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	op := &cachev1alpha1.MemcachedOperation{}
+	if err := r.k8.Get(ctx, req.NamespacedName, op); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("memcached operation not found. Ignoring since object must be deleted")
+			return stop()
+		}
+
+		log.Error(err, "Failed to get memcached operation")
+		return requeueWith(err)
+	}
+
+	if isFinished(op) {
+		return r.expire(ctx, op)
+	}
+
+	memcached := &cachev1alpha1.Memcached{}
+	key := types.NamespacedName{Name: op.Spec.MemcachedRef.Name, Namespace: op.Namespace}
+	if err := r.k8.Get(ctx, key, memcached); err != nil {
+		if apierrors.IsNotFound(err) {
+			return r.finish(ctx, op, cachev1alpha1.MemcachedOperationFailed,
+				fmt.Sprintf("Memcached (%s) not found", key.Name))
+		}
+
+		log.Error(err, "Failed to get memcached")
+		return requeueWith(err)
+	}
+
+	pods, err := listPodsForMemcached(ctx, r.k8, memcached)
+	if err != nil {
+		log.Error(err, "Failed to list pods for memcached")
+		return requeueWith(err)
+	}
+	// a restarted pod may be gone before its replacement is created
+	if len(pods) == 0 && len(op.Status.Pods) == 0 {
+		return r.finish(ctx, op, cachev1alpha1.MemcachedOperationFailed,
+			fmt.Sprintf("Memcached (%s) has no pods", key.Name))
+	}
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+
+	if op.Status.StartTime == nil {
+		op.Status.StartTime = r.now()
+	}
+	op.Status.Phase = cachev1alpha1.MemcachedOperationRunning
+
+	result := ctrl.Result{}
+	if op.Spec.Action == cachev1alpha1.MemcachedOperationRestart {
+		if result, err = r.restartNext(ctx, op, memcached, pods); err != nil {
+			log.Error(err, "Failed to update memcached operation status")
+			return requeueWith(err)
+		}
+	} else {
+		r.runOnAll(ctx, op, pods)
+	}
+
+	if phase, done := operationPhase(op, pods); done {
+		return r.finish(ctx, op, phase, fmt.Sprintf("%s finished on %d pods", op.Spec.Action, len(pods)))
+	}
+
+	if err := r.updateStatus(ctx, op); err != nil {
+		log.Error(err, "Failed to update memcached operation status")
+		return requeueWith(err)
+	}
+
+	return result, nil
+}
+
+// updateStatus writes the status of the operation. The controller is the only
+// writer of the status, so after a conflict the same status is written on top
+// of the operation read anew.
+func (r *MemcachedOperationReconciler) updateStatus(ctx context.Context, op *cachev1alpha1.MemcachedOperation) error {
+	status := op.Status.DeepCopy()
+	return r.k8.StatusUpdateWithRetry(ctx, op, func() error {
+		op.Status = *status
+		return nil
+	})
+}
+
+// runOnAll runs the action against every pod which has no result yet.
+func (r *MemcachedOperationReconciler) runOnAll(
+	ctx context.Context,
+	op *cachev1alpha1.MemcachedOperation,
+	pods []corev1.Pod,
+) {
+	for _, pod := range pods {
+		if podResult(op, pod.Name) != nil {
+			continue
+		}
+
+		result := cachev1alpha1.MemcachedOperationPodResult{
+			Name:      pod.Name,
+			StartTime: r.now(),
+		}
+		switch op.Spec.Action {
+		case cachev1alpha1.MemcachedOperationFlush:
+			r.flush(ctx, pod, &result)
+		case cachev1alpha1.MemcachedOperationStats:
+			r.stats(ctx, pod, &result)
+		default:
+			result.Phase = cachev1alpha1.MemcachedOperationFailed
+			result.Message = fmt.Sprintf("unknown action %s", op.Spec.Action)
+		}
+		result.CompletionTime = r.now()
+
+		op.Status.Pods = append(op.Status.Pods, result)
+	}
+}
+
+func (r *MemcachedOperationReconciler) flush(
+	ctx context.Context,
+	pod corev1.Pod,
+	result *cachev1alpha1.MemcachedOperationPodResult,
+) {
+	if err := flushPod(ctx, r.mc, pod); err != nil {
+		result.Phase = cachev1alpha1.MemcachedOperationFailed
+		result.Message = err.Error()
+		return
+	}
+
+	result.Phase = cachev1alpha1.MemcachedOperationSucceeded
+	result.Message = "OK"
+}
+
+func (r *MemcachedOperationReconciler) stats(
+	ctx context.Context,
+	pod corev1.Pod,
+	result *cachev1alpha1.MemcachedOperationPodResult,
+) {
+	stats, err := r.statsOf(ctx, pod)
+	if err != nil {
+		result.Phase = cachev1alpha1.MemcachedOperationFailed
+		result.Message = err.Error()
+		return
+	}
+
+	result.Stats = stats
+	result.Phase = cachev1alpha1.MemcachedOperationSucceeded
+	result.Message = fmt.Sprintf("collected %d stats", len(result.Stats))
+}
+
+// restartNext restarts the pods one after another. The pods are recorded as
+// Pending when the restart starts, their replacements are not restarted again.
+// The next pod is restarted once the previous one was restarted and is ready
+// again. A pod which fails to restart stops the operation, the remaining pods
+// are not restarted.
+func (r *MemcachedOperationReconciler) restartNext(
+	ctx context.Context,
+	op *cachev1alpha1.MemcachedOperation,
+	memcached *cachev1alpha1.Memcached,
+	pods []corev1.Pod,
+) (ctrl.Result, error) {
+	if len(op.Status.Pods) == 0 {
+		for _, pod := range pods {
+			op.Status.Pods = append(op.Status.Pods, cachev1alpha1.MemcachedOperationPodResult{
+				Name:  pod.Name,
+				Phase: cachev1alpha1.MemcachedOperationPending,
+			})
+		}
+	}
+
+	for i := range op.Status.Pods {
+		result := &op.Status.Pods[i]
+		pod := podNamed(pods, result.Name)
+
+		switch result.Phase {
+		case cachev1alpha1.MemcachedOperationFailed:
+			return ctrl.Result{}, nil
+		case cachev1alpha1.MemcachedOperationSucceeded:
+			continue
+		case cachev1alpha1.MemcachedOperationPending:
+			if pod == nil {
+				result.Phase = cachev1alpha1.MemcachedOperationSucceeded
+				result.Message = "replaced before the restart"
+				result.CompletionTime = r.now()
+				continue
+			}
+			return r.restart(ctx, op, memcached, *pod)
+		}
+
+		if restarted(memcached, pods, pod, result.StartTime) {
+			result.Phase = cachev1alpha1.MemcachedOperationSucceeded
+			result.Message = "restarted"
+			result.CompletionTime = r.now()
+			continue
+		}
+
+		remaining := restartTimeout - r.clock.Since(result.StartTime.Time)
+		if remaining < 0 {
+			result.Phase = cachev1alpha1.MemcachedOperationFailed
+			result.Message = fmt.Sprintf("pod not ready %s after restart", restartTimeout)
+			result.CompletionTime = r.now()
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// restart restarts the memcached of the pod. With warm restart memcached is
+// stopped with 'shutdown graceful' and the kubelet restarts the container in
+// place, which restores the cache from the memory file. Without warm restart
+// the pod is deleted and the ReplicaSet replaces it, memcached starts cold
+// either way. The Running result is written before, a failed status write must
+// not restart the same pod twice.
+func (r *MemcachedOperationReconciler) restart(
+	ctx context.Context,
+	op *cachev1alpha1.MemcachedOperation,
+	memcached *cachev1alpha1.Memcached,
+	pod corev1.Pod,
+) (ctrl.Result, error) {
+	warm := warmRestartEnabled(memcached)
+
+	result := podResult(op, pod.Name)
+	result.Phase = cachev1alpha1.MemcachedOperationRunning
+	result.StartTime = r.now()
+	result.Message = "pod deleted"
+	if warm {
+		result.Message = "shutdown graceful sent"
+	}
+	if err := r.updateStatus(ctx, op); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var err error
+	if warm {
+		err = r.shutdown(ctx, pod)
+	} else {
+		err = client.IgnoreNotFound(r.k8.Delete(ctx, &pod))
+	}
+	if err != nil {
+		// the status was read anew if the write above conflicted
+		result = podResult(op, pod.Name)
+		result.Phase = cachev1alpha1.MemcachedOperationFailed
+		result.Message = err.Error()
+		result.CompletionTime = r.now()
+	}
+
+	return ctrl.Result{RequeueAfter: restartTimeout}, nil
+}
+
+// restarted is true once the restart triggered at the given time completed.
+// An in-place restart completed when the memcached container of the pod is
+// ready again. A deleted pod completed when it's gone and the ReplicaSet
+// replaced it, i.e. the Memcached has as many ready pods as its size.
+func restarted(
+	memcached *cachev1alpha1.Memcached,
+	pods []corev1.Pod,
+	pod *corev1.Pod,
+	triggeredAt *metav1.Time,
+) bool {
+	if warmRestartEnabled(memcached) {
+		return pod != nil && restartedAndReady(*pod, triggeredAt)
+	}
+
+	return pod == nil && readyPods(pods) >= memcached.Spec.Size
+}
+
+// readyPods returns the number of pods which are not terminating and whose
+// memcached container is ready.
+func readyPods(pods []corev1.Pod) int32 {
+	ready := int32(0)
+	for _, pod := range pods {
+		if cs := memcachedContainerStatus(pod); pod.DeletionTimestamp == nil && cs != nil && cs.Ready {
+			ready++
+		}
+	}
+	return ready
+}
+
+func podNamed(pods []corev1.Pod, name string) *corev1.Pod {
+	for i := range pods {
+		if pods[i].Name == name {
+			return &pods[i]
+		}
+	}
+	return nil
+}
+
+// restartedAndReady is true if the memcached container started again after the
+// restart was triggered and is ready to serve.
+func restartedAndReady(pod corev1.Pod, triggeredAt *metav1.Time) bool {
+	cs := memcachedContainerStatus(pod)
+	if cs == nil || cs.State.Running == nil || !cs.Ready {
+		return false
+	}
+
+	// metav1.Time is stored with second precision
+	return !cs.State.Running.StartedAt.Before(triggeredAt)
+}
+
+// statsOf returns the operation stats of the pod, other keys are dropped.
+func (r *MemcachedOperationReconciler) statsOf(ctx context.Context, pod corev1.Pod) (map[string]string, error) {
+	addr, err := podAddr(pod)
+	if err != nil {
+		return nil, err
+	}
+	all, err := r.mc.Stats(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := map[string]string{}
+	for _, key := range operationStats {
+		if value, ok := all[key]; ok {
+			stats[key] = value
+		}
+	}
+	return stats, nil
+}
+
+func (r *MemcachedOperationReconciler) shutdown(ctx context.Context, pod corev1.Pod) error {
+	addr, err := podAddr(pod)
+	if err != nil {
+		return err
+	}
+	return r.mc.Shutdown(ctx, addr)
+}
+
+// operationPhase returns the terminal phase of the operation once every pod has
+// a terminal result. A restart runs against the pods recorded when it started
+// and is done as soon as one pod failed.
+func operationPhase(op *cachev1alpha1.MemcachedOperation, pods []corev1.Pod) (cachev1alpha1.MemcachedOperationPhase, bool) {
+	var results []*cachev1alpha1.MemcachedOperationPodResult
+	if op.Spec.Action == cachev1alpha1.MemcachedOperationRestart {
+		for i := range op.Status.Pods {
+			results = append(results, &op.Status.Pods[i])
+		}
+	} else {
+		for _, pod := range pods {
+			results = append(results, podResult(op, pod.Name))
+		}
+	}
+
+	failed, succeeded := 0, 0
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		switch result.Phase {
+		case cachev1alpha1.MemcachedOperationFailed:
+			failed++
+		case cachev1alpha1.MemcachedOperationSucceeded:
+			succeeded++
+		}
+	}
+
+	switch {
+	case failed > 0 && op.Spec.Action == cachev1alpha1.MemcachedOperationRestart:
+		return cachev1alpha1.MemcachedOperationFailed, true
+	case failed+succeeded < len(results):
+		return "", false
+	case failed > 0:
+		return cachev1alpha1.MemcachedOperationFailed, true
+	default:
+		return cachev1alpha1.MemcachedOperationSucceeded, true
+	}
+}
+
+func podResult(op *cachev1alpha1.MemcachedOperation, name string) *cachev1alpha1.MemcachedOperationPodResult {
+	for i := range op.Status.Pods {
+		if op.Status.Pods[i].Name == name {
+			return &op.Status.Pods[i]
+		}
+	}
+	return nil
+}
+
+func isFinished(op *cachev1alpha1.MemcachedOperation) bool {
+	return op.Status.Phase == cachev1alpha1.MemcachedOperationSucceeded ||
+		op.Status.Phase == cachev1alpha1.MemcachedOperationFailed
+}
+
+// finish moves the operation into a terminal phase and requeues it for the
+// deletion after the TTL.
+func (r *MemcachedOperationReconciler) finish(
+	ctx context.Context,
+	op *cachev1alpha1.MemcachedOperation,
+	phase cachev1alpha1.MemcachedOperationPhase,
+	message string,
+) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	now := r.now()
+	if op.Status.StartTime == nil {
+		op.Status.StartTime = now
+	}
+	op.Status.Phase = phase
+	op.Status.CompletionTime = now
+	op.Status.Message = message
+	if err := r.updateStatus(ctx, op); err != nil {
+		log.Error(err, "Failed to update memcached operation status")
+		return requeueWith(err)
+	}
+
+	log.Info("memcached operation finished", "phase", phase, "message", message)
+	return ctrl.Result{RequeueAfter: ttlFor(op)}, nil
+}
+
+// expire deletes a finished operation once its TTL expired, which keeps the
+// history of operations bounded.
+func (r *MemcachedOperationReconciler) expire(
+	ctx context.Context,
+	op *cachev1alpha1.MemcachedOperation,
+) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	finishedAt := op.Status.CompletionTime
+	if finishedAt == nil {
+		finishedAt = &op.CreationTimestamp
+	}
+
+	if remaining := r.clock.Until(finishedAt.Add(ttlFor(op))); remaining > 0 {
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+
+	log.Info("deleting memcached operation after its TTL expired")
+	if err := r.k8.Delete(ctx, op); client.IgnoreNotFound(err) != nil {
+		log.Error(err, "Failed to delete memcached operation")
+		return requeueWith(err)
+	}
+
+	return stop()
+}
+
+// now returns the time of the clock for the start and completion times, the
+// restart timeout and the TTL are measured from them.
+func (r *MemcachedOperationReconciler) now() *metav1.Time {
+	return ptr.To(metav1.NewTime(r.clock.Now()))
+}
+
+func ttlFor(op *cachev1alpha1.MemcachedOperation) time.Duration {
+	return time.Duration(ptr.Deref(op.Spec.TTLSecondsAfterFinished, defaultOperationTTLSeconds)) * time.Second
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MemcachedOperationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&cachev1alpha1.MemcachedOperation{}).
+		// Watch the memcached pods, a restart continues as soon as the restarted
+		// pod is ready again or was replaced. Other pods are kept out of the cache
+		// by CacheOptions.
+		Watches(
+			&corev1.Pod{},
+			handler.EnqueueRequestsFromMapFunc(r.restartsForPod),
+			builder.WithPredicates(predicate.NewPredicateFuncs(isMemcachedPod)),
+		).
+		Named("memcachedoperation").
+		Complete(r)
+}
+
+// restartsForPod maps a memcached pod to the unfinished Restart operations of
+// its Memcached.
+func (r *MemcachedOperationReconciler) restartsForPod(ctx context.Context, obj client.Object) []reconcile.Request {
+	ops := &cachev1alpha1.MemcachedOperationList{}
+	if err := r.k8.List(ctx, ops, client.InNamespace(obj.GetNamespace())); err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to list memcached operations for pod", "pod", obj.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, op := range ops.Items {
+		if op.Spec.Action != cachev1alpha1.MemcachedOperationRestart || isFinished(&op) ||
+			op.Spec.MemcachedRef.Name != obj.GetLabels()[instanceLabel] {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&op)})
+	}
+	return requests
+}
@@ -0,0 +1,435 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cachev1alpha1 "example.com/m/v2/api/v1alpha1"
+	"example.com/m/v2/internal/controller/infra"
+)
+
+var _ = Describe("MemcachedOperation Controller", func() {
+	const memcachedName = "operation-memcached"
+	opNamespacedName := types.NamespacedName{Name: "test-operation", Namespace: "default"}
+	memcachedNamespacedName := types.NamespacedName{Name: memcachedName, Namespace: "default"}
+
+	var memcachedServer *memcachedStub
+
+	BeforeEach(func() {
+		stats := infra.MemcachedStubResponse{Lines: []string{
+			"STAT pid 1", "STAT curr_items 42", "STAT slab_reassign_rescues 7", "END",
+		}}
+		memcachedServer = newMemcachedStub(infra.MemcachedStubResponses{
+			{Command: "stats"}: {stats, stats},
+		})
+	})
+
+	AfterEach(func() {
+		op := &cachev1alpha1.MemcachedOperation{}
+		if err := k8sClient.Get(ctx, opNamespacedName, op); err == nil {
+			Expect(k8sClient.Delete(ctx, op)).To(Succeed())
+		}
+	})
+
+	Context("When the Memcached has pods", func() {
+		BeforeEach(func() {
+			createMemcachedCR(memcachedName, ctx, memcachedNamespacedName, &cachev1alpha1.Memcached{})
+			createOperationPod("operation-pod-a", "10.0.0.1", memcachedNamespacedName)
+			createOperationPod("operation-pod-b", "10.0.0.2", memcachedNamespacedName)
+		})
+
+		AfterEach(func() {
+			cleanUp(memcachedNamespacedName, false)
+		})
+
+		It("should flush every pod and succeed", func() {
+			createOperation(opNamespacedName, memcachedName, cachev1alpha1.MemcachedOperationFlush)
+			r := newOperationReconciler(memcachedServer)
+
+			result := reconcileOperationOnce(r, opNamespacedName)
+			Expect(result.RequeueAfter).To(Equal(time.Hour))
+
+			Expect(memcachedServer.sent()).To(ConsistOf("10.0.0.1:11211 flush_all", "10.0.0.2:11211 flush_all"))
+			op := getOperation(opNamespacedName)
+			Expect(op.Status.Phase).To(Equal(cachev1alpha1.MemcachedOperationSucceeded))
+			Expect(op.Status.StartTime).NotTo(BeNil())
+			Expect(op.Status.CompletionTime).NotTo(BeNil())
+			Expect(op.Status.Pods).To(HaveLen(2))
+			for _, pod := range op.Status.Pods {
+				Expect(pod.Phase).To(Equal(cachev1alpha1.MemcachedOperationSucceeded))
+				Expect(pod.CompletionTime).NotTo(BeNil())
+			}
+		})
+
+		It("should collect the operation stats of every pod", func() {
+			createOperation(opNamespacedName, memcachedName, cachev1alpha1.MemcachedOperationStats)
+			r := newOperationReconciler(memcachedServer)
+
+			_ = reconcileOperationOnce(r, opNamespacedName)
+
+			op := getOperation(opNamespacedName)
+			Expect(op.Status.Phase).To(Equal(cachev1alpha1.MemcachedOperationSucceeded))
+			Expect(op.Status.Pods).To(HaveLen(2))
+			Expect(op.Status.Pods[0].Stats).To(Equal(map[string]string{"pid": "1", "curr_items": "42"}))
+		})
+
+		It("should fail if a pod can't be flushed", func() {
+			memcachedServer.failFor("10.0.0.2:11211", "flush_all")
+			createOperation(opNamespacedName, memcachedName, cachev1alpha1.MemcachedOperationFlush)
+			r := newOperationReconciler(memcachedServer)
+
+			_ = reconcileOperationOnce(r, opNamespacedName)
+
+			op := getOperation(opNamespacedName)
+			Expect(op.Status.Phase).To(Equal(cachev1alpha1.MemcachedOperationFailed))
+			Expect(op.Status.Pods[0].Phase).To(Equal(cachev1alpha1.MemcachedOperationSucceeded))
+			Expect(op.Status.Pods[1].Phase).To(Equal(cachev1alpha1.MemcachedOperationFailed))
+			Expect(op.Status.Pods[1].Message).To(ContainSubstring("connection refused"))
+		})
+
+		It("should write the status after a conflict", func() {
+			createOperation(opNamespacedName, memcachedName, cachev1alpha1.MemcachedOperationFlush)
+			r := newOperationReconciler(memcachedServer)
+			operationGVK := cachev1alpha1.GroupVersion.WithKind("MemcachedOperation")
+			r.k8 = infra.NewK8CliStubWithConfig(infra.StubConfig{
+				Faults: infra.StubFaults{infra.ConflictFault("StatusUpdate", operationGVK, opNamespacedName, 1)},
+				K8:     k8sClient,
+			})
+
+			_ = reconcileOperationOnce(r, opNamespacedName)
+
+			Expect(memcachedServer.sent()).To(HaveLen(2))
+			op := getOperation(opNamespacedName)
+			Expect(op.Status.Phase).To(Equal(cachev1alpha1.MemcachedOperationSucceeded))
+			Expect(op.Status.Pods).To(HaveLen(2))
+		})
+
+		It("should not restart a pod twice if the status write failed", func() {
+			enableWarmRestart(memcachedNamespacedName)
+			createOperation(opNamespacedName, memcachedName, cachev1alpha1.MemcachedOperationRestart)
+			r := newOperationReconciler(memcachedServer)
+			r.k8 = infra.NewK8CliStubWithConfig(infra.StubConfig{
+				Faults: infra.StubFaults{{
+					Method: "StatusUpdate", Name: opNamespacedName.Name, Call: 2,
+					Err: apierrors.NewServiceUnavailable("unavailable"),
+				}},
+				K8: k8sClient,
+			})
+
+			By("Fail to write the status after the shutdown was sent")
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: opNamespacedName})
+			Expect(apierrors.IsServiceUnavailable(err)).To(BeTrue())
+
+			By("Wait for the pod instead of sending the shutdown again")
+			_ = reconcileOperationOnce(r, opNamespacedName)
+			Expect(memcachedServer.sent()).To(ConsistOf("10.0.0.1:11211 shutdown graceful"))
+			Expect(getOperation(opNamespacedName).Status.Pods).To(HaveLen(1))
+			Expect(getOperation(opNamespacedName).Status.Pods[0].Phase).To(Equal(cachev1alpha1.MemcachedOperationRunning))
+		})
+
+		It("should restart one pod after another in place with warm restart", func() {
+			enableWarmRestart(memcachedNamespacedName)
+			createOperation(opNamespacedName, memcachedName, cachev1alpha1.MemcachedOperationRestart)
+			r := newOperationReconciler(memcachedServer)
+
+			By("Restart the first pod")
+			result := reconcileOperationOnce(r, opNamespacedName)
+			Expect(result.RequeueAfter).To(Equal(restartTimeout))
+			Expect(memcachedServer.sent()).To(ConsistOf("10.0.0.1:11211 shutdown graceful"))
+			Expect(getOperation(opNamespacedName).Status.Phase).To(Equal(cachev1alpha1.MemcachedOperationRunning))
+
+			By("Wait for the first pod to be ready again")
+			_ = reconcileOperationOnce(r, opNamespacedName)
+			Expect(memcachedServer.sent()).To(HaveLen(1))
+
+			By("Restart the second pod after the first one is ready")
+			restartOperationPod("operation-pod-a", memcachedNamespacedName)
+			_ = reconcileOperationOnce(r, opNamespacedName)
+			Expect(memcachedServer.sent()).To(HaveLen(2))
+
+			By("Succeed after the second pod is ready")
+			restartOperationPod("operation-pod-b", memcachedNamespacedName)
+			_ = reconcileOperationOnce(r, opNamespacedName)
+
+			op := getOperation(opNamespacedName)
+			Expect(op.Status.Phase).To(Equal(cachev1alpha1.MemcachedOperationSucceeded))
+			Expect(op.Status.Pods).To(HaveLen(2))
+		})
+
+		It("should replace one pod after another without warm restart", func() {
+			updateMemcached(memcachedNamespacedName, func(m *cachev1alpha1.Memcached) {
+				m.Spec.Size = 2
+			})
+			createOperation(opNamespacedName, memcachedName, cachev1alpha1.MemcachedOperationRestart)
+			r := newOperationReconciler(memcachedServer)
+
+			By("Delete the first pod")
+			result := reconcileOperationOnce(r, opNamespacedName)
+			Expect(result.RequeueAfter).To(Equal(restartTimeout))
+			expectPodGone("operation-pod-a", memcachedNamespacedName)
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "operation-pod-b", Namespace: "default"},
+				&corev1.Pod{})).To(Succeed())
+
+			By("Wait for the replacement of the first pod")
+			_ = reconcileOperationOnce(r, opNamespacedName)
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "operation-pod-b", Namespace: "default"},
+				&corev1.Pod{})).To(Succeed())
+
+			By("Delete the second pod after the first one was replaced")
+			createOperationPod("operation-pod-c", "10.0.0.3", memcachedNamespacedName)
+			_ = reconcileOperationOnce(r, opNamespacedName)
+			expectPodGone("operation-pod-b", memcachedNamespacedName)
+
+			By("Succeed after the second pod was replaced without restarting the replacements")
+			createOperationPod("operation-pod-d", "10.0.0.4", memcachedNamespacedName)
+			_ = reconcileOperationOnce(r, opNamespacedName)
+
+			Expect(memcachedServer.sent()).To(BeEmpty())
+			op := getOperation(opNamespacedName)
+			Expect(op.Status.Phase).To(Equal(cachev1alpha1.MemcachedOperationSucceeded))
+			Expect(op.Status.Pods).To(HaveLen(2))
+			Expect(op.Status.Pods[0].Name).To(Equal("operation-pod-a"))
+			Expect(op.Status.Pods[1].Name).To(Equal("operation-pod-b"))
+		})
+	})
+
+	Context("When a memcached pod changes", func() {
+		It("should reconcile the unfinished restarts of its Memcached", func() {
+			operation := func(name, memcached string, action cachev1alpha1.MemcachedOperationAction) *cachev1alpha1.MemcachedOperation {
+				return &cachev1alpha1.MemcachedOperation{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+					Spec: cachev1alpha1.MemcachedOperationSpec{
+						MemcachedRef: corev1.LocalObjectReference{Name: memcached},
+						Action:       action,
+					},
+				}
+			}
+			finished := operation("finished-restart", memcachedName, cachev1alpha1.MemcachedOperationRestart)
+			finished.Status.Phase = cachev1alpha1.MemcachedOperationSucceeded
+			r := newOperationReconciler(memcachedServer)
+			r.k8 = infra.NewK8CliStubWithConfig(infra.StubConfig{
+				Scheme: k8sClient.Scheme(),
+				Objects: []client.Object{
+					operation("restart", memcachedName, cachev1alpha1.MemcachedOperationRestart),
+					operation("flush", memcachedName, cachev1alpha1.MemcachedOperationFlush),
+					operation("other-restart", "other-memcached", cachev1alpha1.MemcachedOperationRestart),
+					finished,
+				},
+			})
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Name: "operation-pod", Namespace: "default", Labels: labelsForMemcached(memcachedName),
+			}}
+
+			Expect(r.restartsForPod(ctx, pod)).To(ConsistOf(reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: "restart", Namespace: "default"},
+			}))
+		})
+	})
+
+	Context("When the Memcached doesn't exist", func() {
+		It("should fail the operation", func() {
+			createOperation(opNamespacedName, "non-existing", cachev1alpha1.MemcachedOperationFlush)
+			r := newOperationReconciler(memcachedServer)
+
+			_ = reconcileOperationOnce(r, opNamespacedName)
+
+			op := getOperation(opNamespacedName)
+			Expect(op.Status.Phase).To(Equal(cachev1alpha1.MemcachedOperationFailed))
+			Expect(op.Status.Message).To(ContainSubstring("not found"))
+			Expect(memcachedServer.sent()).To(BeEmpty())
+		})
+
+		It("should delete a finished operation after its TTL expired", func() {
+			createOperation(opNamespacedName, "non-existing", cachev1alpha1.MemcachedOperationFlush, 0)
+			r := newOperationReconciler(memcachedServer)
+
+			By("Finish the operation")
+			result := reconcileOperationOnce(r, opNamespacedName)
+			Expect(result.RequeueAfter).To(BeZero())
+
+			By("Delete the operation")
+			_ = reconcileOperationOnce(r, opNamespacedName)
+			err := k8sClient.Get(ctx, opNamespacedName, &cachev1alpha1.MemcachedOperation{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should keep a finished operation until its TTL expired", func() {
+			createOperation(opNamespacedName, "non-existing", cachev1alpha1.MemcachedOperationFlush, 60)
+			r := newOperationReconciler(memcachedServer)
+			r.clock = infra.NewClockStub()
+
+			By("Finish the operation")
+			result := reconcileOperationOnce(r, opNamespacedName)
+			Expect(result.RequeueAfter).To(Equal(time.Minute))
+
+			By("Keep the operation before the TTL expired")
+			r.clock.Advance(30 * time.Second)
+			result = reconcileOperationOnce(r, opNamespacedName)
+			Expect(result.RequeueAfter).To(BeNumerically("~", 30*time.Second, time.Second))
+			Expect(k8sClient.Get(ctx, opNamespacedName, &cachev1alpha1.MemcachedOperation{})).To(Succeed())
+
+			By("Delete the operation after the TTL expired")
+			r.clock.Advance(31 * time.Second)
+			_ = reconcileOperationOnce(r, opNamespacedName)
+			err := k8sClient.Get(ctx, opNamespacedName, &cachev1alpha1.MemcachedOperation{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+})
+
+// memcachedStub is the nulled memcached client of a test. It replies like a
+// healthy memcached unless configured otherwise and records the commands sent.
+type memcachedStub struct {
+	responses infra.MemcachedStubResponses
+	cli       *infra.MemcachedCliImpl
+	commands  *infra.CommandTracker
+}
+
+func newMemcachedStub(responses infra.MemcachedStubResponses) *memcachedStub {
+	if responses == nil {
+		responses = infra.MemcachedStubResponses{}
+	}
+	cli := infra.NewMemcachedCliStub(responses)
+	return &memcachedStub{responses: responses, cli: cli, commands: cli.TrackCommands()}
+}
+
+// failFor fails the next command sent to the address like an unreachable
+// memcached.
+func (m *memcachedStub) failFor(addr, command string) {
+	key := infra.MemcachedStubKey{Addr: addr, Command: command}
+	m.responses[key] = append(m.responses[key], infra.MemcachedStubResponse{
+		Err: fmt.Errorf("dial tcp %s: connection refused", addr),
+	})
+}
+
+// sent returns the commands sent, e.g. '10.0.0.1:11211 flush_all'.
+func (m *memcachedStub) sent() []string {
+	var sent []string
+	for _, command := range m.commands.Data() {
+		sent = append(sent, command.Addr+" "+command.Command)
+	}
+	return sent
+}
+
+func newOperationReconciler(memcachedServer *memcachedStub) *MemcachedOperationReconciler {
+	r := NewOperationReconciler(k8sClient)
+	r.mc = memcachedServer.cli
+	return r
+}
+
+func reconcileOperationOnce(r *MemcachedOperationReconciler, t types.NamespacedName) reconcile.Result {
+	result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: t})
+	Expect(err).NotTo(HaveOccurred())
+	return result
+}
+
+func createOperation(
+	t types.NamespacedName,
+	memcachedName string,
+	action cachev1alpha1.MemcachedOperationAction,
+	ttlSeconds ...int32,
+) {
+	op := &cachev1alpha1.MemcachedOperation{
+		ObjectMeta: metav1.ObjectMeta{Name: t.Name, Namespace: t.Namespace},
+		Spec: cachev1alpha1.MemcachedOperationSpec{
+			MemcachedRef: corev1.LocalObjectReference{Name: memcachedName},
+			Action:       action,
+		},
+	}
+	if len(ttlSeconds) > 0 {
+		op.Spec.TTLSecondsAfterFinished = ptr.To(ttlSeconds[0])
+	}
+	Expect(k8sClient.Create(ctx, op)).To(Succeed())
+}
+
+func getOperation(t types.NamespacedName) *cachev1alpha1.MemcachedOperation {
+	op := &cachev1alpha1.MemcachedOperation{}
+	Expect(k8sClient.Get(ctx, t, op)).To(Succeed())
+	return op
+}
+
+// createOperationPod creates a ready memcached pod with an IP. There is no
+// kubelet in the test environment so the status is set manually.
+func createOperationPod(name, ip string, memcached types.NamespacedName) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: memcached.Namespace,
+			Labels:    labelsForMemcached(memcached.Name),
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: memcachedContainerName, Image: memcachedImage}},
+		},
+	}
+	Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+	DeferCleanup(func() {
+		// the pod is gone if the Restart operation deleted it
+		Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, pod, client.GracePeriodSeconds(0)))).To(Succeed())
+	})
+
+	pod.Status = corev1.PodStatus{
+		Phase:  corev1.PodRunning,
+		PodIP:  ip,
+		PodIPs: []corev1.PodIP{{IP: ip}},
+		ContainerStatuses: []corev1.ContainerStatus{{
+			Name:    memcachedContainerName,
+			Image:   memcachedImage,
+			Ready:   true,
+			Started: ptr.To(true),
+			State: corev1.ContainerState{
+				Running: &corev1.ContainerStateRunning{StartedAt: metav1.NewTime(time.Now().Add(-time.Hour))},
+			},
+		}},
+	}
+	Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+}
+
+func enableWarmRestart(memcached types.NamespacedName) {
+	updateMemcached(memcached, func(m *cachev1alpha1.Memcached) {
+		m.Spec.WarmRestart = &cachev1alpha1.WarmRestartSpec{Enabled: true}
+	})
+}
+
+// expectPodGone expects the pod to be deleted. Pods which aren't scheduled to a
+// node are deleted right away, there is no kubelet in the test environment.
+func expectPodGone(name string, memcached types.NamespacedName) {
+	err := k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: memcached.Namespace}, &corev1.Pod{})
+	Expect(apierrors.IsNotFound(err)).To(BeTrue())
+}
+
+// restartOperationPod simulates the kubelet restarting the memcached container.
+func restartOperationPod(name string, memcached types.NamespacedName) {
+	pod := &corev1.Pod{}
+	Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: memcached.Namespace}, pod)).To(Succeed())
+
+	cs := &pod.Status.ContainerStatuses[0]
+	cs.RestartCount++
+	cs.State.Running.StartedAt = metav1.NewTime(time.Now().Add(time.Second))
+	Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+}
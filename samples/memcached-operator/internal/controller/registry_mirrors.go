@@ -0,0 +1,88 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+)
+
+const defaultRegistry = "docker.io"
+
+// RegistryMirrors maps a source registry to the registry which is used instead
+// when pulling images, e.g. "docker.io" -> "localhost:5000".
+type RegistryMirrors map[string]string
+
+// ParseRegistryMirrors parses a comma separated list of "source=mirror" pairs,
+// e.g. "docker.io=localhost:5000,quay.io=localhost:5001". An empty string
+// results in no mirrors.
+func ParseRegistryMirrors(s string) (RegistryMirrors, error) {
+	mirrors := RegistryMirrors{}
+	if strings.TrimSpace(s) == "" {
+		return mirrors, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		from, to, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid registry mirror %q, expected format 'source=mirror'", pair)
+		}
+		mirrors[normalizeRegistry(from)] = strings.TrimSuffix(to, "/")
+	}
+
+	return mirrors, nil
+}
+
+// Rewrite replaces the registry of the image with its mirror. Images without
+// an explicit registry are resolved against docker.io the same way the
+// container runtime does, e.g. "memcached:1.6" becomes
+// "localhost:5000/library/memcached:1.6" for a docker.io mirror. Images from
+// registries without a mirror are returned unchanged.
+func (m RegistryMirrors) Rewrite(image string) string {
+	if len(m) == 0 {
+		return image
+	}
+
+	registry, repository := splitImage(image)
+	mirror, ok := m[registry]
+	if !ok {
+		return image
+	}
+
+	return mirror + "/" + repository
+}
+
+func splitImage(image string) (string, string) {
+	host, rest, ok := strings.Cut(image, "/")
+	if !ok {
+		return defaultRegistry, "library/" + image
+	}
+
+	// the first path component is only a registry if it looks like a host
+	if !strings.ContainsAny(host, ".:") && host != "localhost" {
+		return defaultRegistry, image
+	}
+
+	return normalizeRegistry(host), rest
+}
+
+func normalizeRegistry(registry string) string {
+	if registry == "index.docker.io" {
+		return defaultRegistry
+	}
+	return registry
+}
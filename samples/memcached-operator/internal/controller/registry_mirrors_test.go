@@ -0,0 +1,68 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registry mirrors", func() {
+	DescribeTable("parsing the operator flag",
+		func(flag string, expected RegistryMirrors, expectErr bool) {
+			mirrors, err := ParseRegistryMirrors(flag)
+			if expectErr {
+				Expect(err).To(HaveOccurred())
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mirrors).To(Equal(expected))
+		},
+		Entry("empty flag", "", RegistryMirrors{}, false),
+		Entry("single mirror", "docker.io=localhost:5000",
+			RegistryMirrors{"docker.io": "localhost:5000"}, false),
+		Entry("multiple mirrors with spaces", "docker.io=localhost:5000, quay.io=localhost:5001/",
+			RegistryMirrors{"docker.io": "localhost:5000", "quay.io": "localhost:5001"}, false),
+		Entry("docker hub alias", "index.docker.io=localhost:5000",
+			RegistryMirrors{"docker.io": "localhost:5000"}, false),
+		Entry("missing mirror", "docker.io=", nil, true),
+		Entry("missing separator", "docker.io", nil, true),
+	)
+
+	DescribeTable("rewriting images",
+		func(image, expected string) {
+			mirrors := RegistryMirrors{"docker.io": "localhost:5000", "quay.io": "localhost:5001"}
+			Expect(mirrors.Rewrite(image)).To(Equal(expected))
+		},
+		Entry("official image without registry", "memcached:1.6.26-alpine3.19",
+			"localhost:5000/library/memcached:1.6.26-alpine3.19"),
+		Entry("user image without registry", "bitnami/memcached:1.6",
+			"localhost:5000/bitnami/memcached:1.6"),
+		Entry("explicit docker.io registry", "docker.io/library/memcached:1.6",
+			"localhost:5000/library/memcached:1.6"),
+		Entry("other mirrored registry", "quay.io/org/memcached:1.6",
+			"localhost:5001/org/memcached:1.6"),
+		Entry("registry without mirror", "ghcr.io/org/memcached:1.6",
+			"ghcr.io/org/memcached:1.6"),
+		Entry("local registry", "localhost:5000/memcached:1.6",
+			"localhost:5000/memcached:1.6"),
+	)
+
+	It("should not rewrite images without mirrors", func() {
+		Expect(RegistryMirrors(nil).Rewrite("memcached:1.6")).To(Equal("memcached:1.6"))
+	})
+})